@@ -3,27 +3,59 @@
 package config
 
 import (
-	"uos-squid-exporter/pkg/utils"
+	"time"
+
 	"github.com/alecthomas/kingpin"
 	"github.com/sirupsen/logrus"
+	"uos-squid-exporter/pkg/utils"
 )
 
 var (
-	ScrapeUrl       *string
-	Insecure        *bool
-	SquidHostname   *string
-	SquidPort       *int
-	Login           *string
-	Password        *string
-	ExtractTimes    *bool
+	ScrapeUrl          *string
+	Insecure           *bool
+	SquidHostname      *string
+	SquidPort          *int
+	Login              *string
+	Password           *string
+	ExtractTimes       *bool
+	LegacyServiceTimes *bool
+	Transport          *string
+	TLSEnabled         *bool
+	TLSCAFile          *string
+	TLSCertFile        *string
+	TLSKeyFile         *string
+	TLSServerName      *string
+	DialTimeout        *time.Duration
+	ReadTimeout        *time.Duration
+	TotalTimeout       *time.Duration
+	MemPoolsTop        *int
+	MemPoolsAllow      *[]string
+	ClientListTop      *int
+	ClientListLocalnet *bool
+	PconnAggregateOnly *bool
+	Collect            *[]string
+	// flagsSetByUser 记录在命令行中显式指定的参数，只有这些参数会覆盖配置文件
+	flagsSetByUser  = map[string]bool{}
 	DefaultSettings = Settings{
 		//ScrapeUri: "http://127.0.0.1:24220/api/plugins.json",
-		Insecure: false,
+		Insecure:      false,
 		SquidHostname: "localhost",
 		SquidPort:     3128,
 		Login:         "",
 		Password:      "",
 		ExtractTimes:  true,
+		Transport:     "auto",
+		Timeout: TimeoutSettings{
+			Dial:  10 * time.Second,
+			Read:  10 * time.Second,
+			Total: 30 * time.Second,
+		},
+		MemPools: MemPoolSettings{
+			Top: 20,
+		},
+		ClientList: ClientListSettings{
+			Top: 20,
+		},
 	}
 )
 
@@ -31,30 +63,107 @@ func init() {
 	ScrapeUrl = kingpin.Flag("scrape_uri",
 		"Scrape URI").
 		Short('s').
+		Action(markSetByUser("scrape_uri")).
 		String()
 	Insecure = kingpin.Flag("insecure",
 		"Ignore server certificate if using https, Default: false.").
+		Action(markSetByUser("insecure")).
 		Bool()
 	SquidHostname = kingpin.Flag("squid.hostname",
 		"Hostname of the Squid server").
 		Default("localhost").
+		Action(markSetByUser("squid.hostname")).
 		String()
 	SquidPort = kingpin.Flag("squid.port",
 		"Port of the Squid server").
 		Default("3128").
+		Action(markSetByUser("squid.port")).
 		Int()
 	Login = kingpin.Flag("squid.login",
 		"Login for the Squid server").
 		Default("").
+		Action(markSetByUser("squid.login")).
 		String()
 	Password = kingpin.Flag("squid.password",
 		"Password for the Squid server").
 		Default("").
+		Action(markSetByUser("squid.password")).
 		String()
 	ExtractTimes = kingpin.Flag("squid.extractTimes",
 		"Extract service time metrics").
 		Default("true").
+		Action(markSetByUser("squid.extractTimes")).
+		Bool()
+	LegacyServiceTimes = kingpin.Flag("squid.legacyServiceTimes",
+		"Also export service times under the old per-percentile metric names").
+		Action(markSetByUser("squid.legacyServiceTimes")).
+		Bool()
+	Transport = kingpin.Flag("squid.transport",
+		"Cache manager transport: auto, http (/squid-internal-mgr/) or cache_object").
+		Default("auto").
+		Action(markSetByUser("squid.transport")).
+		Enum("auto", "http", "cache_object")
+	TLSEnabled = kingpin.Flag("squid.tls",
+		"Connect to the cache manager over TLS (https_port)").
+		Action(markSetByUser("squid.tls")).
+		Bool()
+	TLSCAFile = kingpin.Flag("squid.tls.caFile",
+		"CA bundle used to verify the cache manager certificate").
+		Action(markSetByUser("squid.tls.caFile")).
+		String()
+	TLSCertFile = kingpin.Flag("squid.tls.certFile",
+		"Client certificate presented to the cache manager").
+		Action(markSetByUser("squid.tls.certFile")).
+		String()
+	TLSKeyFile = kingpin.Flag("squid.tls.keyFile",
+		"Private key of the client certificate").
+		Action(markSetByUser("squid.tls.keyFile")).
+		String()
+	TLSServerName = kingpin.Flag("squid.tls.serverName",
+		"Server name used for SNI and certificate verification").
+		Action(markSetByUser("squid.tls.serverName")).
+		String()
+	DialTimeout = kingpin.Flag("squid.timeout.dial",
+		"Timeout for connecting to the cache manager, including the TLS handshake").
+		Default("10s").
+		Action(markSetByUser("squid.timeout.dial")).
+		Duration()
+	ReadTimeout = kingpin.Flag("squid.timeout.read",
+		"Timeout for reading one cache manager page").
+		Default("10s").
+		Action(markSetByUser("squid.timeout.read")).
+		Duration()
+	TotalTimeout = kingpin.Flag("squid.timeout.total",
+		"Upper bound for one scrape of the target, further limited by the Prometheus scrape timeout").
+		Default("30s").
+		Action(markSetByUser("squid.timeout.total")).
+		Duration()
+	MemPoolsTop = kingpin.Flag("squid.memPools.top",
+		"Number of mgr:mem pools with the most allocated bytes to export").
+		Default("20").
+		Action(markSetByUser("squid.memPools.top")).
+		Int()
+	MemPoolsAllow = kingpin.Flag("squid.memPools.allow",
+		"mgr:mem pool to export, can be repeated; overrides squid.memPools.top").
+		Action(markSetByUser("squid.memPools.allow")).
+		Strings()
+	ClientListTop = kingpin.Flag("squid.clientList.top",
+		"Number of mgr:client_list clients with the most requests to export, the rest are summed as other").
+		Default("20").
+		Action(markSetByUser("squid.clientList.top")).
+		Int()
+	ClientListLocalnet = kingpin.Flag("squid.clientList.groupByLocalnet",
+		"Group mgr:client_list clients by the localnet networks found in squid.conf").
+		Action(markSetByUser("squid.clientList.groupByLocalnet")).
+		Bool()
+	PconnAggregateOnly = kingpin.Flag("squid.pconn.aggregateOnly",
+		"Export only per-pool totals of idle mgr:pconn connections, without the destination label").
+		Action(markSetByUser("squid.pconn.aggregateOnly")).
 		Bool()
+	Collect = kingpin.Flag("squid.collect",
+		"Cache manager page to request, can be repeated; \"default\" stands for all non-optional pages").
+		Action(markSetByUser("squid.collect")).
+		Strings()
 	if *ScrapeUrl != "" {
 		if err := utils.ValidateURI(*ScrapeUrl); err != nil {
 			logrus.Warnf("Invalid scrape uri: %s", err)
@@ -62,22 +171,232 @@ func init() {
 			*ScrapeUrl = DefaultSettings.ScrapeUri
 		}
 	}
-
-	if *Insecure {
-		logrus.Warn("Insecure mode enabled, this is not recommended for production use.")
-	}
 }
 
 type Settings struct {
-	ScrapeUri string `yaml:"scrape_uri"`
-	Insecure  bool   `yaml:"insecure"`
+	ScrapeUri     string `yaml:"scrape_uri"`
+	Insecure      bool   `yaml:"insecure"`
 	SquidHostname string `yaml:"hostname"`
 	SquidPort     int    `yaml:"port"`
 	Login         string `yaml:"login"`
 	Password      string `yaml:"password"`
 	ExtractTimes  bool   `yaml:"extractTimes"`
+	// LegacyServiceTimes 为true时同时导出旧的服务时间指标名，用于迁移仪表盘
+	LegacyServiceTimes bool               `yaml:"legacyServiceTimes"`
+	Transport          string             `yaml:"transport"`
+	TLS                TLSSettings        `yaml:"tls"`
+	Timeout            TimeoutSettings    `yaml:"timeout"`
+	MemPools           MemPoolSettings    `yaml:"memPools"`
+	ClientList         ClientListSettings `yaml:"clientList"`
+	Pconn              PconnSettings      `yaml:"pconn"`
+	// Collect 要请求的管理页面，为空时请求全部非可选页面，events 等可选页面只有列出时才请求
+	Collect []string `yaml:"collect"`
+}
+
+// MemPoolSettings 限制 mgr:mem 导出的内存池数量，Allow 不为空时只导出其中的内存池，
+// 否则导出已分配字节数最多的 Top 个内存池
+type MemPoolSettings struct {
+	Top   int      `yaml:"top"`
+	Allow []string `yaml:"allow"`
+}
+
+// ClientListSettings 限制 mgr:client_list 导出的客户端数量，GroupByLocalnet 为true时
+// 按squid.conf中 localnet 的网段合并客户端，合并后导出请求数最多的 Top 个，其余合并为 other
+type ClientListSettings struct {
+	Top             int  `yaml:"top"`
+	GroupByLocalnet bool `yaml:"groupByLocalnet"`
+}
+
+// PconnSettings 控制 mgr:pconn 的导出，AggregateOnly 为true时不按目标输出空闲连接
+type PconnSettings struct {
+	AggregateOnly bool `yaml:"aggregateOnly"`
+}
+
+// TimeoutSettings 请求缓存管理器的超时配置，Prometheus的抓取超时更短时以其为准
+type TimeoutSettings struct {
+	Dial  time.Duration `yaml:"dial"`
+	Read  time.Duration `yaml:"read"`
+	Total time.Duration `yaml:"total"`
+}
+
+// TLSSettings 连接缓存管理器时使用的TLS配置，跳过证书校验由 insecure 控制
+type TLSSettings struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+// ModuleSettings 是 /probe 使用的命名模块，目标地址由请求参数 target 给出
+type ModuleSettings struct {
+	Login     string          `yaml:"login"`
+	Password  string          `yaml:"password"`
+	Transport string          `yaml:"transport"`
+	Insecure  bool            `yaml:"insecure"`
+	TLS       TLSSettings     `yaml:"tls"`
+	Timeout   TimeoutSettings `yaml:"timeout"`
+	// LegacyServiceTimes 为true时同时导出旧的服务时间指标名
+	LegacyServiceTimes bool `yaml:"legacyServiceTimes"`
+	// Collect 要请求的管理页面，例如 counters、info、service_times，为空时请求全部非可选页面
+	Collect []string `yaml:"collect"`
+}
+
+// Settings 把模块转换为目标配置，未设置的字段使用默认值
+func (m ModuleSettings) Settings() Settings {
+	settings := DefaultSettings
+	settings.Login = m.Login
+	settings.Password = m.Password
+	settings.Transport = m.Transport
+	settings.Insecure = m.Insecure
+	settings.TLS = m.TLS
+	settings.Timeout = m.Timeout
+	settings.LegacyServiceTimes = m.LegacyServiceTimes
+	settings.Collect = m.Collect
+	settings.applyDefaults()
+	return settings
+}
+
+// InstanceSettings 是 instances: 中的一个静态Squid实例，未设置的字段使用默认值
+type InstanceSettings struct {
+	// Name 作为 instance_name 标签的值，为空时使用 hostname:port
+	Name     string `yaml:"name"`
+	Settings `yaml:",inline"`
+	// ConfigPath 和 ConfigDir 为该实例的 squid.conf 路径和配置目录，为空时使用通用配置
+	ConfigPath string `yaml:"config_path"`
+	ConfigDir  string `yaml:"config_dir"`
+}
+
+// UnmarshalYAML 在解码前填充默认值，使未写出的字段保持默认
+func (i *InstanceSettings) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain InstanceSettings
+	*i = InstanceSettings{Settings: DefaultSettings}
+	if err := unmarshal((*plain)(i)); err != nil {
+		return err
+	}
+	i.applyDefaults()
+	return nil
 }
 
 type SquidSettings struct {
-	Settings Settings `yaml:"squid"`
+	Settings  Settings                  `yaml:"squid"`
+	Modules   map[string]ModuleSettings `yaml:"modules"`
+	Instances []InstanceSettings        `yaml:"instances"`
+}
+
+// markSetByUser 返回一个在参数被显式指定时进行记录的动作
+func markSetByUser(name string) kingpin.Action {
+	return func(*kingpin.ParseContext) error {
+		flagsSetByUser[name] = true
+		return nil
+	}
+}
+
+// ApplyFlags 使用命令行中显式指定的参数覆盖配置，
+// 配合以 DefaultSettings 为初始值解码的YAML，得到 默认值 < 配置文件 < 命令行 的优先级
+func (s *Settings) ApplyFlags() {
+	if flagsSetByUser["scrape_uri"] {
+		s.ScrapeUri = *ScrapeUrl
+	}
+	if flagsSetByUser["insecure"] {
+		s.Insecure = *Insecure
+	}
+	if flagsSetByUser["squid.hostname"] {
+		s.SquidHostname = *SquidHostname
+	}
+	if flagsSetByUser["squid.port"] {
+		s.SquidPort = *SquidPort
+	}
+	if flagsSetByUser["squid.login"] {
+		s.Login = *Login
+	}
+	if flagsSetByUser["squid.password"] {
+		s.Password = *Password
+	}
+	if flagsSetByUser["squid.extractTimes"] {
+		s.ExtractTimes = *ExtractTimes
+	}
+	if flagsSetByUser["squid.legacyServiceTimes"] {
+		s.LegacyServiceTimes = *LegacyServiceTimes
+	}
+	if flagsSetByUser["squid.transport"] {
+		s.Transport = *Transport
+	}
+	if flagsSetByUser["squid.tls"] {
+		s.TLS.Enabled = *TLSEnabled
+	}
+	if flagsSetByUser["squid.tls.caFile"] {
+		s.TLS.CAFile = *TLSCAFile
+	}
+	if flagsSetByUser["squid.tls.certFile"] {
+		s.TLS.CertFile = *TLSCertFile
+	}
+	if flagsSetByUser["squid.tls.keyFile"] {
+		s.TLS.KeyFile = *TLSKeyFile
+	}
+	if flagsSetByUser["squid.tls.serverName"] {
+		s.TLS.ServerName = *TLSServerName
+	}
+	if flagsSetByUser["squid.timeout.dial"] {
+		s.Timeout.Dial = *DialTimeout
+	}
+	if flagsSetByUser["squid.timeout.read"] {
+		s.Timeout.Read = *ReadTimeout
+	}
+	if flagsSetByUser["squid.timeout.total"] {
+		s.Timeout.Total = *TotalTimeout
+	}
+	if flagsSetByUser["squid.memPools.top"] {
+		s.MemPools.Top = *MemPoolsTop
+	}
+	if flagsSetByUser["squid.memPools.allow"] {
+		s.MemPools.Allow = *MemPoolsAllow
+	}
+	if flagsSetByUser["squid.clientList.top"] {
+		s.ClientList.Top = *ClientListTop
+	}
+	if flagsSetByUser["squid.clientList.groupByLocalnet"] {
+		s.ClientList.GroupByLocalnet = *ClientListLocalnet
+	}
+	if flagsSetByUser["squid.pconn.aggregateOnly"] {
+		s.Pconn.AggregateOnly = *PconnAggregateOnly
+	}
+	if flagsSetByUser["squid.collect"] {
+		s.Collect = *Collect
+	}
+
+	s.applyDefaults()
+
+	if s.Insecure {
+		logrus.Warn("Insecure mode enabled, this is not recommended for production use.")
+	}
+}
+
+// applyDefaults 把配置文件中缺失或非法的值回退到默认值
+func (s *Settings) applyDefaults() {
+	if s.SquidHostname == "" {
+		s.SquidHostname = DefaultSettings.SquidHostname
+	}
+	if s.Transport == "" {
+		s.Transport = DefaultSettings.Transport
+	}
+	if s.Timeout.Dial <= 0 {
+		s.Timeout.Dial = DefaultSettings.Timeout.Dial
+	}
+	if s.Timeout.Read <= 0 {
+		s.Timeout.Read = DefaultSettings.Timeout.Read
+	}
+	if s.Timeout.Total <= 0 {
+		s.Timeout.Total = DefaultSettings.Timeout.Total
+	}
+	if s.MemPools.Top <= 0 {
+		s.MemPools.Top = DefaultSettings.MemPools.Top
+	}
+	if s.ClientList.Top <= 0 {
+		s.ClientList.Top = DefaultSettings.ClientList.Top
+	}
+	if s.SquidPort <= 0 || s.SquidPort > 65535 {
+		logrus.Warnf("Invalid squid port %d, use default port: %d", s.SquidPort, DefaultSettings.SquidPort)
+		s.SquidPort = DefaultSettings.SquidPort
+	}
 }
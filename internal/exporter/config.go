@@ -28,6 +28,7 @@ var (
 		MetricsPath:     "/metrics",
 		SquidConfigPath: "/etc/squid/squid.conf",
 		SquidConfigDir:  "/etc/squid/",
+		InstanceWorkers: 4,
 	}
 )
 
@@ -37,12 +38,11 @@ func init() {
 		Default("/etc/uos-exporter/squid-exporter.yaml").
 		String()
 
-	SquidConfigPath = kingpin.Flag("squid-config", "Path to squid configuration file").
-		Default("/etc/squid/squid.conf").
+	// 未指定时为空，使用配置文件中的值
+	SquidConfigPath = kingpin.Flag("squid-config", "Path to squid configuration file (default: /etc/squid/squid.conf)").
 		String()
 
-	SquidConfigDir = kingpin.Flag("squid-config-dir", "Path to squid configuration directory").
-		Default("/etc/squid/").
+	SquidConfigDir = kingpin.Flag("squid-config-dir", "Path to squid configuration directory (default: /etc/squid/)").
 		String()
 }
 
@@ -53,6 +53,8 @@ type Config struct {
 	MetricsPath     string        `yaml:"metricsPath"`
 	SquidConfigPath string        `yaml:"squidConfigPath"`
 	SquidConfigDir  string        `yaml:"squidConfigDir"`
+	// InstanceWorkers 同时抓取的 instances 实例数上限
+	InstanceWorkers int `yaml:"instanceWorkers"`
 }
 
 func Unpack(config interface{}) error {
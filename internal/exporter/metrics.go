@@ -2,8 +2,20 @@
 // SPDX-License-Identifier: MIT
 package exporter
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type Metric interface {
 	Collect(ch chan<- prometheus.Metric)
 }
+
+// ScrapeAware 由需要在每次抓取开始前准备状态的指标实现，
+// 例如在同一次抓取内共享缓存管理器数据的快照。
+// BeginScrape 的 ctx 携带本次抓取的超时和取消信号，EndScrape 在抓取结束后调用
+type ScrapeAware interface {
+	BeginScrape(ctx context.Context)
+	EndScrape()
+}
@@ -3,29 +3,61 @@
 package exporter
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
+	"context"
 	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var defaultReg *Registry
 
+// instanceLabel 是静态实例指标携带的实例名标签
+const instanceLabel = "instance_name"
+
+// instanceRegistry 保存一个静态实例的全部指标
+type instanceRegistry struct {
+	name string
+	reg  *Registry
+}
+
+var (
+	instancesMu sync.RWMutex
+	instances   []instanceRegistry
+	// instanceSlots 限制同时抓取的实例数
+	instanceSlots = make(chan struct{}, 1)
+)
+
 func init() {
 	defaultReg = NewRegistry()
 }
 
+// registerInstance 注册一个静态实例的注册表
+func registerInstance(name string, reg *Registry) {
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	instances = append(instances, instanceRegistry{name: name, reg: reg})
+}
+
+// setInstanceWorkers 设置同时抓取的实例数上限
+func setInstanceWorkers(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	instancesMu.Lock()
+	defer instancesMu.Unlock()
+	instanceSlots = make(chan struct{}, workers)
+}
+
 type Registry struct {
-	metrics []Metric
-	mu      sync.RWMutex
+	metrics  []Metric
+	mu       sync.RWMutex
+	scrapeMu sync.Mutex
 }
 
 func Register(metric Metric) {
 	defaultReg.Register(metric)
 }
 
-func RegisterPrometheus(reg *prometheus.Registry) {
-	reg.MustRegister(defaultReg)
-}
-
 func NewRegistry() *Registry {
 	return &Registry{
 		metrics: []Metric{},
@@ -48,8 +80,79 @@ func (r *Registry) Describe(descs chan<- *prometheus.Desc) {
 }
 
 func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	r.CollectContext(context.Background(), ch)
+}
+
+// CollectContext 在指定上下文中完成一次抓取，ctx 结束时未完成的缓存管理器请求会被中断
+func (r *Registry) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	// 同一时间只进行一次抓取，保证所有指标读取的是同一份快照
+	r.scrapeMu.Lock()
+	defer r.scrapeMu.Unlock()
+
 	metrics := r.GetMetrics()
+	for _, m := range metrics {
+		if sa, ok := m.(ScrapeAware); ok {
+			sa.BeginScrape(ctx)
+			defer sa.EndScrape()
+		}
+	}
 	for _, m := range metrics {
 		m.Collect(ch)
 	}
 }
+
+// scrapeCollector 把一次HTTP抓取的上下文绑定到 Registry
+type scrapeCollector struct {
+	ctx context.Context
+	reg *Registry
+}
+
+func (c *scrapeCollector) Describe(descs chan<- *prometheus.Desc) {
+}
+
+func (c *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.reg.CollectContext(c.ctx, ch)
+}
+
+// instanceCollector 在抓取上下文中收集一个静态实例，占用一个并行抓取名额
+type instanceCollector struct {
+	ctx   context.Context
+	reg   *Registry
+	slots chan struct{}
+}
+
+func (c *instanceCollector) Describe(descs chan<- *prometheus.Desc) {
+}
+
+func (c *instanceCollector) Collect(ch chan<- prometheus.Metric) {
+	// prometheus.Registry 为每个收集器启动独立的协程，这里限制同时请求Squid的实例数
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	c.reg.CollectContext(c.ctx, ch)
+}
+
+// NewProbeRegistry 为一次 /probe 请求创建只包含指定目标收集器的 prometheus.Registry
+func NewProbeRegistry(ctx context.Context, config *SquidConfig) *prometheus.Registry {
+	targetReg := NewRegistry()
+	for _, collector := range newTargetCollectors(config) {
+		targetReg.Register(collector)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&scrapeCollector{ctx: ctx, reg: targetReg})
+	return reg
+}
+
+// NewScrapeRegistry 为一次抓取创建 prometheus.Registry，所有指标在 ctx 内收集
+func NewScrapeRegistry(ctx context.Context) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&scrapeCollector{ctx: ctx, reg: defaultReg})
+
+	instancesMu.RLock()
+	defer instancesMu.RUnlock()
+	for _, instance := range instances {
+		wrapped := prometheus.WrapRegistererWith(prometheus.Labels{instanceLabel: instance.name}, reg)
+		wrapped.MustRegister(&instanceCollector{ctx: ctx, reg: instance.reg, slots: instanceSlots})
+	}
+	return reg
+}
@@ -3,60 +3,339 @@
 package exporter
 
 import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"uos-squid-exporter/config"
 	"uos-squid-exporter/internal/metrics"
 
 	"github.com/sirupsen/logrus"
 )
 
-// InitSquidCollector 初始化Squid收集器
-func InitSquidCollector(squidConfigPath string) {
+// InitSquidCollector 使用解析完成的Squid目标配置初始化Squid收集器
+func InitSquidCollector(squidConfig *SquidConfig) {
 	logrus.Info("Initializing Squid collector...")
 
-	// 创建基础的Squid配置
-	squidConfig := createSquidConfig()
-
-	logrus.Infof("Squid collector initialized with hostname: %s, port: %d",
-		squidConfig.Hostname, squidConfig.Port)
+	logrus.Infof("Squid collector initialized with hostname: %s, port: %d, transport: %s, tls: %t",
+		squidConfig.Hostname, squidConfig.Port, squidConfig.Transport, squidConfig.TLS != nil)
 
 	// 注册基础指标收集器
 	registerBasicCollectors(squidConfig)
 
 	// 注册配置文件收集器
-	registerConfigCollector(squidConfigPath)
+	registerConfigCollector(squidConfig.ConfigPath)
 
 	// 注册配置文件列表收集器
-	registerConfigFilesCollector()
+	registerConfigFilesCollector(squidConfig.ConfigDir)
 
 	logrus.Info("Squid collector initialization completed")
 }
 
-// SquidConfig Squid配置结构
+// InitSquidInstances 为 instances: 中的每个实例初始化全部收集器，
+// 每个实例的指标带有 instance_name 标签，抓取时最多 workers 个实例并行
+func InitSquidInstances(instances []config.InstanceSettings, common Config) error {
+	logrus.Infof("Initializing %d squid instances...", len(instances))
+
+	// 先检查全部实例，避免部分实例已注册后才发现错误
+	configs := make([]*SquidConfig, 0, len(instances))
+	seen := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		squidConfig, err := NewInstanceConfig(instance, common)
+		if err != nil {
+			return err
+		}
+		if seen[squidConfig.Name] {
+			return fmt.Errorf("duplicate squid instance name %q", squidConfig.Name)
+		}
+		seen[squidConfig.Name] = true
+		configs = append(configs, squidConfig)
+	}
+
+	for _, squidConfig := range configs {
+		logrus.Infof("Squid instance %s initialized with hostname: %s, port: %d, transport: %s, config: %s",
+			squidConfig.Name, squidConfig.Hostname, squidConfig.Port, squidConfig.Transport, squidConfig.ConfigPath)
+		registerInstance(squidConfig.Name, newInstanceRegistry(squidConfig))
+	}
+	setInstanceWorkers(common.InstanceWorkers)
+
+	logrus.Info("Squid instances initialization completed")
+	return nil
+}
+
+// SquidConfig Squid目标配置结构
 type SquidConfig struct {
+	// Name 为静态实例的名称，单目标和 /probe 时为空
+	Name         string
 	Hostname     string
 	Port         int
 	Login        string
 	Password     string
 	ExtractTimes bool
+	// LegacyServiceTimes 为true时同时注册旧的服务时间指标名
+	LegacyServiceTimes bool
+	// MemPools 限制 mgr:mem 导出的内存池数量
+	MemPools metrics.MemPoolOptions
+	// ClientList 限制 mgr:client_list 导出的客户端数量，ClientListByLocalnet 为true时
+	// 按squid.conf中 localnet 的网段合并客户端
+	ClientList           metrics.ClientListOptions
+	ClientListByLocalnet bool
+	// Pconn 控制 mgr:pconn 是否按目标输出空闲连接
+	Pconn        metrics.PconnOptions
 	Headers      []string
+	Transport    string
+	MgrPath      string
+	TLS          *metrics.TLSOptions
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	TotalTimeout time.Duration
+	// Collect 要请求的管理页面，为空时请求全部非可选页面
+	Collect    []string
+	ConfigPath string
+	ConfigDir  string
 }
 
-// createSquidConfig 创建默认的Squid配置
-func createSquidConfig() *SquidConfig {
-	return &SquidConfig{
-		Hostname:     "localhost",
-		Port:         3128,
-		Login:        "",
-		Password:     "",
-		ExtractTimes: true, // 默认启用服务时间提取
+// NewSquidConfig 根据已合并命令行参数的squid配置段和通用配置创建Squid目标配置，
+// collect 中有未知页面时返回错误
+func NewSquidConfig(settings config.Settings, common Config) (*SquidConfig, error) {
+	if err := validateCollect(settings.Collect); err != nil {
+		return nil, err
+	}
+
+	configPath := common.SquidConfigPath
+	if configPath == "" {
+		configPath = DefaultConfig.SquidConfigPath
+	}
+	configDir := common.SquidConfigDir
+	if configDir == "" {
+		configDir = DefaultConfig.SquidConfigDir
+	}
+
+	squidConfig := &SquidConfig{
+		Hostname:           settings.SquidHostname,
+		Port:               settings.SquidPort,
+		Login:              settings.Login,
+		Password:           settings.Password,
+		ExtractTimes:       settings.ExtractTimes,
+		LegacyServiceTimes: settings.LegacyServiceTimes,
+		MemPools: metrics.MemPoolOptions{
+			Top:   settings.MemPools.Top,
+			Allow: settings.MemPools.Allow,
+		},
+		ClientList: metrics.ClientListOptions{
+			Top: settings.ClientList.Top,
+		},
+		ClientListByLocalnet: settings.ClientList.GroupByLocalnet,
+		Pconn: metrics.PconnOptions{
+			AggregateOnly: settings.Pconn.AggregateOnly,
+		},
 		Headers:      []string{},
+		Transport:    settings.Transport,
+		DialTimeout:  settings.Timeout.Dial,
+		ReadTimeout:  settings.Timeout.Read,
+		TotalTimeout: settings.Timeout.Total,
+		Collect:      settings.Collect,
+		ConfigPath:   configPath,
+		ConfigDir:    configDir,
 	}
+
+	// scrape_uri 同时指定地址和请求方式，优先于 hostname/port/transport
+	if settings.ScrapeUri != "" {
+		endpoint, err := metrics.ParseScrapeURI(settings.ScrapeUri)
+		if err != nil {
+			logrus.Warnf("Ignoring scrape uri: %v", err)
+		} else {
+			squidConfig.Hostname = endpoint.Hostname
+			squidConfig.Port = endpoint.Port
+			squidConfig.Transport = endpoint.Transport
+			squidConfig.MgrPath = endpoint.MgrPath
+			if endpoint.TLS {
+				settings.TLS.Enabled = true
+			}
+		}
+	}
+
+	if settings.TLS.Enabled {
+		squidConfig.TLS = &metrics.TLSOptions{
+			CAFile:     settings.TLS.CAFile,
+			CertFile:   settings.TLS.CertFile,
+			KeyFile:    settings.TLS.KeyFile,
+			ServerName: settings.TLS.ServerName,
+			Insecure:   settings.Insecure,
+		}
+	}
+
+	if !metrics.ValidTransport(squidConfig.Transport) {
+		logrus.Warnf("Unknown cache manager transport %q, use %s", squidConfig.Transport, metrics.TransportAuto)
+		squidConfig.Transport = metrics.TransportAuto
+	}
+
+	return squidConfig, nil
+}
+
+// NewInstanceConfig 根据 instances: 中的一项创建目标配置
+func NewInstanceConfig(instance config.InstanceSettings, common Config) (*SquidConfig, error) {
+	if instance.ConfigPath != "" {
+		common.SquidConfigPath = instance.ConfigPath
+	}
+	if instance.ConfigDir != "" {
+		common.SquidConfigDir = instance.ConfigDir
+	}
+
+	squidConfig, err := NewSquidConfig(instance.Settings, common)
+	if err != nil {
+		if instance.Name != "" {
+			return nil, fmt.Errorf("instance %s: %w", instance.Name, err)
+		}
+		return nil, fmt.Errorf("instance: %w", err)
+	}
+	squidConfig.Name = instance.Name
+	if squidConfig.Name == "" {
+		squidConfig.Name = net.JoinHostPort(squidConfig.Hostname, strconv.Itoa(squidConfig.Port))
+	}
+	return squidConfig, nil
+}
+
+// 可以通过 collect 选择的管理页面
+const (
+	PageCounters     = "counters"
+	PageInfo         = "info"
+	PageServiceTimes = "service_times"
+	// PageAverages5min 和 PageAverages60min 为Squid计算好的5分钟和60分钟平均值
+	PageAverages5min  = "5min"
+	PageAverages60min = "60min"
+	PageStoreDir      = "storedir"
+	PageMem           = "mem"
+	PageFDs           = "filedescriptors"
+	PageIPCache       = "ipcache"
+	PageFQDNCache     = "fqdncache"
+	PageIDNS          = "idns"
+	PageServerList    = "server_list"
+	PageUtilization   = "utilization"
+	PageActive        = "active_requests"
+	PageClientList    = "client_list"
+	PagePconn         = "pconn"
+	PageRefresh       = "refresh"
+	PageStoreIO       = "store_io"
+	PageAIOCounts     = "squidaio_counts"
+	// PageEvents、PageCommEpoll 和 PageCommSelect 为可选页面，只有在 collect 中列出时才请求
+	PageEvents     = "events"
+	PageCommEpoll  = "comm_epoll_incoming"
+	PageCommSelect = "comm_select_incoming"
+	// PageDefault 不是管理页面，在 collect 中表示全部非可选页面，例如 ["default", "events"]
+	PageDefault = "default"
+)
+
+// knownPages 是 collect 中可以使用的值，值为true的页面为可选页面，默认不请求
+var knownPages = map[string]bool{
+	PageCounters: false, PageInfo: false, PageServiceTimes: false,
+	PageAverages5min: false, PageAverages60min: false,
+	PageStoreDir: false, PageMem: false, PageFDs: false,
+	PageIPCache: false, PageFQDNCache: false, PageIDNS: false,
+	PageServerList: false, PageUtilization: false, PageActive: false,
+	PageClientList: false, PagePconn: false, PageRefresh: false,
+	PageStoreIO: false, PageAIOCounts: false,
+	PageEvents: true, PageCommEpoll: true, PageCommSelect: true,
+	PageDefault: false,
+}
+
+// validateCollect 检查 collect 中的页面是否都是已知页面
+func validateCollect(pages []string) error {
+	for _, page := range pages {
+		if _, ok := knownPages[page]; !ok {
+			return fmt.Errorf("unknown page %q in collect", page)
+		}
+	}
+	return nil
+}
+
+// ValidateModules 检查每个 /probe 模块的 collect，有未知页面时返回错误
+func ValidateModules(modules map[string]config.ModuleSettings) error {
+	for name, module := range modules {
+		if err := validateCollect(module.Collect); err != nil {
+			return fmt.Errorf("module %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// collects 判断是否需要请求指定的管理页面。Collect 为空时请求全部非可选页面，
+// 否则只请求列出的页面，列出 default 时同时请求全部非可选页面
+func (c *SquidConfig) collects(page string) bool {
+	for _, p := range c.Collect {
+		if p == page || (p == PageDefault && !knownPages[page]) {
+			return true
+		}
+	}
+	return len(c.Collect) == 0 && !knownPages[page]
+}
+
+// NewProbeConfig 根据 /probe 的 target 参数和模块创建目标配置，
+// target 可以是 host、host:port 或 scrape_uri 形式的地址
+func NewProbeConfig(target string, module config.ModuleSettings, common Config) (*SquidConfig, error) {
+	settings := module.Settings()
+	if strings.Contains(target, "://") {
+		if _, err := metrics.ParseScrapeURI(target); err != nil {
+			return nil, err
+		}
+		settings.ScrapeUri = target
+	} else {
+		host, port, err := splitTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		settings.SquidHostname = host
+		settings.SquidPort = port
+	}
+
+	squidConfig, err := NewSquidConfig(settings, common)
+	if err != nil {
+		return nil, fmt.Errorf("module: %w", err)
+	}
+	// 本地的squid.conf与远程目标无关，worker数量从目标的缓存管理器探测
+	squidConfig.ConfigPath = ""
+	squidConfig.ConfigDir = ""
+	return squidConfig, nil
+}
+
+// splitTarget 解析 host 或 host:port 形式的目标，未指定端口时使用默认端口
+func splitTarget(target string) (string, int, error) {
+	if target == "" {
+		return "", 0, fmt.Errorf("target is empty")
+	}
+
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		// 没有端口
+		return strings.Trim(target, "[]"), config.DefaultSettings.SquidPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in target %q", target)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("target %q has no host", target)
+	}
+	return host, port, nil
 }
 
 // registerBasicCollectors 注册基础指标收集器
 func registerBasicCollectors(config *SquidConfig) {
 	logrus.Debug("Registering basic collectors...")
 
-	// 注册主要的Squid指标收集器
+	collectors := newTargetCollectors(config)
+	for _, collector := range collectors {
+		Register(collector)
+	}
+
+	logrus.Infof("Basic collectors registration completed, %d collectors registered", len(collectors))
+}
+
+// newTargetCollectors 创建一个Squid目标的全部缓存管理器收集器，它们共享同一个快照源
+func newTargetCollectors(config *SquidConfig) []Metric {
+	// 主要的Squid指标收集器
 	mainCollector := metrics.NewSquidCollector(&metrics.SquidConfig{
 		Hostname:     config.Hostname,
 		Port:         config.Port,
@@ -64,33 +343,213 @@ func registerBasicCollectors(config *SquidConfig) {
 		Password:     config.Password,
 		Headers:      config.Headers,
 		ExtractTimes: config.ExtractTimes,
+		Transport:    config.Transport,
+		MgrPath:      config.MgrPath,
+		TLS:          config.TLS,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		TotalTimeout: config.TotalTimeout,
+		Workers:      squidConfWorkers(config.ConfigPath),
 	})
-	Register(mainCollector)
+	collectors := []Metric{mainCollector}
 
-	// 注册Squid计数器指标
-	counters := metrics.GetSquidCounters()
-	for _, counter := range counters {
-		Register(counter)
+	// 其余收集器共享主收集器的快照源，每次抓取每个页面只请求一次
+	source := mainCollector.Source()
+
+	// Squid计数器指标
+	if config.collects(PageCounters) {
+		for _, counter := range metrics.GetSquidCounters(source) {
+			collectors = append(collectors, counter)
+		}
 	}
-	logrus.Debugf("Registered %d squid counter collectors", len(counters))
 
-	// 注册Squid信息指标
-	infos := metrics.GetSquidInfos()
-	for _, info := range infos {
-		Register(info)
+	// Squid信息指标
+	if config.collects(PageInfo) {
+		for _, info := range metrics.GetSquidInfos(source) {
+			collectors = append(collectors, info)
+		}
+	}
+
+	// 如果启用了服务时间提取，添加服务时间指标
+	if config.ExtractTimes && config.collects(PageServiceTimes) {
+		for _, serviceTime := range metrics.GetSquidServiceTimes(source) {
+			collectors = append(collectors, serviceTime)
+		}
+		// 兼容旧的每个百分位一个指标的名称
+		if config.LegacyServiceTimes {
+			for _, serviceTime := range metrics.GetLegacySquidServiceTimes(source) {
+				collectors = append(collectors, serviceTime)
+			}
+		}
+	}
+
+	// 5分钟和60分钟平均值
+	var averagePages []string
+	for _, page := range []string{PageAverages5min, PageAverages60min} {
+		if config.collects(page) {
+			averagePages = append(averagePages, page)
+		}
+	}
+	if len(averagePages) > 0 {
+		for _, average := range metrics.GetSquidAverages(source, averagePages...) {
+			collectors = append(collectors, average)
+		}
+	}
+
+	// 每个cache_dir的存储指标
+	if config.collects(PageStoreDir) {
+		for _, storeDir := range metrics.GetSquidStoreDirs(source) {
+			collectors = append(collectors, storeDir)
+		}
+	}
+
+	// 内存池指标，数量受 MemPools 限制
+	if config.collects(PageMem) {
+		for _, memPool := range metrics.GetSquidMemPools(source, config.MemPools) {
+			collectors = append(collectors, memPool)
+		}
+	}
+
+	// 按类型、端口类别和状态汇总的文件描述符
+	if config.collects(PageFDs) {
+		for _, fds := range metrics.GetSquidFileDescriptors(source) {
+			collectors = append(collectors, fds)
+		}
+	}
+
+	// IP和FQDN缓存统计
+	if config.collects(PageIPCache) {
+		for _, ipCache := range metrics.GetSquidIPCache(source) {
+			collectors = append(collectors, ipCache)
+		}
+	}
+	if config.collects(PageFQDNCache) {
+		for _, fqdnCache := range metrics.GetSquidFQDNCache(source) {
+			collectors = append(collectors, fqdnCache)
+		}
+	}
+
+	// 内部DNS解析器统计
+	if config.collects(PageIDNS) {
+		for _, idns := range metrics.GetSquidIDNS(source) {
+			collectors = append(collectors, idns)
+		}
+	}
+
+	// cache_peer 统计
+	if config.collects(PageServerList) {
+		for _, peers := range metrics.GetSquidPeers(source) {
+			collectors = append(collectors, peers)
+		}
+	}
+
+	// 按多个时间窗口平均的计数器
+	if config.collects(PageUtilization) {
+		for _, utilization := range metrics.GetSquidUtilization(source) {
+			collectors = append(collectors, utilization)
+		}
+	}
+
+	// 正在处理的请求
+	if config.collects(PageActive) {
+		for _, active := range metrics.GetSquidActiveRequests(source) {
+			collectors = append(collectors, active)
+		}
+	}
+
+	// 客户端统计，数量受 ClientList 限制
+	if config.collects(PageClientList) {
+		clientList := config.ClientList
+		if config.ClientListByLocalnet {
+			clientList.Networks = squidConfLocalNetworks(config.ConfigPath)
+		}
+		for _, clients := range metrics.GetSquidClientList(source, clientList) {
+			collectors = append(collectors, clients)
+		}
+	}
+
+	// 持久连接直方图和空闲连接
+	if config.collects(PagePconn) {
+		for _, pconn := range metrics.GetSquidPconn(source, config.Pconn) {
+			collectors = append(collectors, pconn)
+		}
+	}
+
+	// 新鲜度检查统计
+	if config.collects(PageRefresh) {
+		for _, refresh := range metrics.GetSquidRefresh(source) {
+			collectors = append(collectors, refresh)
+		}
+	}
+
+	// 磁盘I/O统计
+	if config.collects(PageStoreIO) {
+		for _, storeIO := range metrics.GetSquidStoreIO(source) {
+			collectors = append(collectors, storeIO)
+		}
+	}
+	if config.collects(PageAIOCounts) {
+		for _, aio := range metrics.GetSquidAIOCounts(source) {
+			collectors = append(collectors, aio)
+		}
 	}
-	logrus.Debugf("Registered %d squid info collectors", len(infos))
 
-	// 如果启用了服务时间提取，注册服务时间指标
-	if config.ExtractTimes {
-		serviceTimes := metrics.GetSquidServiceTimes()
-		for _, serviceTime := range serviceTimes {
-			Register(serviceTime)
+	// 可选的事件队列和I/O循环统计，只有在 Collect 中列出时才请求
+	if config.collects(PageEvents) {
+		for _, events := range metrics.GetSquidEvents(source) {
+			collectors = append(collectors, events)
+		}
+	}
+	var commPages []string
+	for _, page := range []string{PageCommEpoll, PageCommSelect} {
+		if config.collects(page) {
+			commPages = append(commPages, page)
+		}
+	}
+	if len(commPages) > 0 {
+		for _, comm := range metrics.GetSquidCommIncoming(source, commPages...) {
+			collectors = append(collectors, comm)
 		}
-		logrus.Debugf("Registered %d squid service time collectors", len(serviceTimes))
 	}
 
-	logrus.Info("Basic collectors registration completed")
+	return collectors
+}
+
+// squidConfWorkers 从squid.conf读取SMP worker数量，读取失败或未配置时返回0，由收集器从缓存管理器探测
+func squidConfWorkers(configPath string) int {
+	if configPath == "" {
+		return 0
+	}
+	configData, err := metrics.NewSquidConfigParser(configPath).Parse()
+	if err != nil {
+		logrus.Debugf("Cannot read workers from %s, detecting from cache manager: %v", configPath, err)
+		return 0
+	}
+	return configData.Workers
+}
+
+// squidConfLocalNetworks 从squid.conf读取 localnet 的网段，读取失败时返回空，客户端不按网段合并
+func squidConfLocalNetworks(configPath string) []string {
+	if configPath == "" {
+		return nil
+	}
+	configData, err := metrics.NewSquidConfigParser(configPath).Parse()
+	if err != nil {
+		logrus.Debugf("Cannot read localnet networks from %s: %v", configPath, err)
+		return nil
+	}
+	return configData.LocalNetworks
+}
+
+// newInstanceRegistry 创建包含一个实例全部收集器（含配置文件收集器）的注册表
+func newInstanceRegistry(config *SquidConfig) *Registry {
+	reg := NewRegistry()
+	for _, collector := range newTargetCollectors(config) {
+		reg.Register(collector)
+	}
+	reg.Register(metrics.NewSquidConfigCollector(config.ConfigPath))
+	reg.Register(metrics.NewSquidConfigFilesCollector(config.ConfigDir))
+	return reg
 }
 
 // registerConfigCollector 注册配置文件收集器
@@ -107,13 +566,7 @@ func registerConfigCollector(configPath string) {
 }
 
 // registerConfigFilesCollector 注册配置文件列表收集器
-func registerConfigFilesCollector() {
-	configDir := DefaultConfig.SquidConfigDir
-	if SquidConfigDir != nil && *SquidConfigDir != "" {
-		configDir = *SquidConfigDir
-		logrus.Infof("Using command-line squid config directory: %s", configDir)
-	}
-
+func registerConfigFilesCollector(configDir string) {
 	logrus.Debugf("Registering config files collector for directory: %s", configDir)
 
 	// 创建配置文件列表收集器
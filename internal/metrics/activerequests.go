@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// activeRequestsPage 是正在处理的客户端请求列表所在的管理页面
+const activeRequestsPage = "active_requests"
+
+// activeRequestAges 是请求已处理时间的分组，按上限从小到大排列
+var activeRequestAges = []struct {
+	limit float64
+	label string
+}{
+	{1, "under_1s"},
+	{10, "1s_to_10s"},
+	{60, "10s_to_1m"},
+	{300, "1m_to_5m"},
+	{math.Inf(1), "over_5m"},
+}
+
+// activeRequestDefaultPorts 是URL中没有端口时按协议使用的默认端口
+var activeRequestDefaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// activeRequest 是 mgr:active_requests 中的一个请求，只保留汇总需要的字段
+type activeRequest struct {
+	LogType string
+	URI     string
+	Age     float64
+}
+
+// logType 返回请求当前的访问日志类型，例如 TCP_MISS、TCP_TUNNEL，页面中没有时为 unknown
+func (r activeRequest) logType() string {
+	if r.LogType == "" {
+		return "unknown"
+	}
+	return r.LogType
+}
+
+// port 返回请求的目标端口，URL中没有端口时使用协议的默认端口，无法确定时为 unknown
+func (r activeRequest) port() string {
+	if r.URI == "" {
+		return "unknown"
+	}
+	if !strings.Contains(r.URI, "://") {
+		if _, port, err := net.SplitHostPort(r.URI); err == nil {
+			return port
+		}
+		return "unknown"
+	}
+	u, err := url.Parse(r.URI)
+	if err != nil {
+		return "unknown"
+	}
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if port, ok := activeRequestDefaultPorts[u.Scheme]; ok {
+		return port
+	}
+	return "unknown"
+}
+
+// activeRequestsStats 是 mgr:active_requests 的汇总结果
+type activeRequestsStats struct {
+	Total    float64
+	LogTypes map[string]float64
+	Ports    map[string]float64
+	Ages     map[string]float64
+	Oldest   float64
+}
+
+// activeRequestsParser 逐行解析 mgr:active_requests 并即时汇总，不保存已处理的请求，
+// 每个请求以 "Connection: 0x..." 行开始，以空行结束
+type activeRequestsParser struct {
+	stats   activeRequestsStats
+	current *activeRequest
+}
+
+// newActiveRequestsParser 创建新的解析器，所有时间分组的初始值为0
+func newActiveRequestsParser() *activeRequestsParser {
+	p := &activeRequestsParser{stats: activeRequestsStats{
+		LogTypes: map[string]float64{},
+		Ports:    map[string]float64{},
+		Ages:     map[string]float64{},
+	}}
+	for _, age := range activeRequestAges {
+		p.stats.Ages[age.label] = 0
+	}
+	return p
+}
+
+// parseLine 处理页面中的一行
+func (p *activeRequestsParser) parseLine(line string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		p.finish()
+		return
+	}
+	if strings.HasPrefix(trimmed, "Connection:") {
+		p.finish()
+		p.current = &activeRequest{}
+		return
+	}
+	if p.current == nil {
+		return
+	}
+
+	key, value, _ := strings.Cut(trimmed, " ")
+	switch key {
+	case "uri":
+		p.current.URI = strings.TrimSpace(value)
+	case "logType":
+		p.current.LogType = strings.TrimSpace(value)
+	case "start":
+		// 形如 "start 1700000000.123456 (3.456789 seconds ago)"
+		if _, ago, ok := strings.Cut(value, "("); ok {
+			if fields := strings.Fields(ago); len(fields) > 0 {
+				p.current.Age, _ = strconv.ParseFloat(fields[0], 64)
+			}
+		}
+	}
+}
+
+// finish 汇总当前请求
+func (p *activeRequestsParser) finish() {
+	if p.current == nil {
+		return
+	}
+	request := *p.current
+	p.current = nil
+
+	p.stats.Total++
+	p.stats.LogTypes[request.logType()]++
+	p.stats.Ports[request.port()]++
+	for _, age := range activeRequestAges {
+		if request.Age < age.limit {
+			p.stats.Ages[age.label]++
+			break
+		}
+	}
+	if request.Age > p.stats.Oldest {
+		p.stats.Oldest = request.Age
+	}
+}
+
+// result 返回汇总结果，页面没有以空行结束时同样计入最后一个请求
+func (p *activeRequestsParser) result() activeRequestsStats {
+	p.finish()
+	return p.stats
+}
+
+// GetSquidActiveRequests 返回 mgr:active_requests 的收集器
+func GetSquidActiveRequests(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidActiveRequestsCollector(source)}
+}
+
+// SquidActiveRequestsCollector 把正在处理的请求汇总为按访问日志类型、目标端口和已处理时间分组的数量，
+// 以及最早请求的已处理时间，不按单个请求输出
+type SquidActiveRequestsCollector struct {
+	source    *SnapshotSource
+	total     *prometheus.Desc
+	byLogType *prometheus.Desc
+	byPort    *prometheus.Desc
+	byAge     *prometheus.Desc
+	oldest    *prometheus.Desc
+}
+
+// NewSquidActiveRequestsCollector 创建新的活动请求收集器
+func NewSquidActiveRequestsCollector(source *SnapshotSource) *SquidActiveRequestsCollector {
+	return &SquidActiveRequestsCollector{
+		source: source,
+		total: prometheus.NewDesc("squid_active_requests",
+			"Number of client requests in progress", nil, nil),
+		byLogType: prometheus.NewDesc("squid_active_requests_by_log_type",
+			"Number of client requests in progress by current access log type", []string{"log_type"}, nil),
+		byPort: prometheus.NewDesc("squid_active_requests_by_port",
+			"Number of client requests in progress by destination port", []string{"port"}, nil),
+		byAge: prometheus.NewDesc("squid_active_requests_by_age",
+			"Number of client requests in progress by time since the request started", []string{"age"}, nil),
+		oldest: prometheus.NewDesc("squid_active_requests_oldest_age_seconds",
+			"Time since the oldest client request in progress started in seconds", nil, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidActiveRequestsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.total
+	ch <- c.byLogType
+	ch <- c.byPort
+	ch <- c.byAge
+	ch <- c.oldest
+}
+
+// Collect实现了Collector接口，用于采集指标，页面逐行处理，不保存整个响应
+func (c *SquidActiveRequestsCollector) Collect(ch chan<- prometheus.Metric) {
+	parser := newActiveRequestsParser()
+	if err := c.source.Current().StreamPage(activeRequestsPage, parser.parseLine); err != nil {
+		return
+	}
+	stats := parser.result()
+
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, stats.Total)
+	emitCounts(ch, c.byLogType, stats.LogTypes)
+	emitCounts(ch, c.byPort, stats.Ports)
+	emitCounts(ch, c.byAge, stats.Ages)
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, stats.Oldest)
+}
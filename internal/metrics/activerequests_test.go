@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试逐行汇总活动请求
+func TestActiveRequestsParser(t *testing.T) {
+	page := readPage(t, "active_requests")
+	ages := func(counts ...float64) map[string]float64 {
+		m := map[string]float64{}
+		for i, age := range activeRequestAges {
+			m[age.label] = counts[i]
+		}
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected activeRequestsStats
+	}{
+		{
+			name:  "按访问日志类型和端口汇总",
+			lines: page,
+			expected: activeRequestsStats{
+				Total:    3,
+				LogTypes: map[string]float64{"TCP_MISS": 1, "TCP_HIT": 1, "TCP_TUNNEL": 1},
+				Ports:    map[string]float64{"80": 2, "443": 1},
+				Ages:     ages(1, 0, 1, 0, 1),
+				Oldest:   300.5,
+			},
+		},
+		{
+			name:  "没有请求",
+			lines: nil,
+			expected: activeRequestsStats{
+				LogTypes: map[string]float64{},
+				Ports:    map[string]float64{},
+				Ages:     ages(0, 0, 0, 0, 0),
+			},
+		},
+		{
+			name: "IPv6目标的隧道和URL中的端口",
+			lines: []string{
+				"Connection: 0x55d0c1a3e118\n",
+				"uri [2001:db8::80]:443\n",
+				"logType TCP_TUNNEL\n",
+				"\n",
+				"Connection: 0x55d0c1a3f200\n",
+				"uri https://example.com:8443/upload\n",
+				"logType TCP_MISS\n",
+				"\n",
+				"Connection: 0x55d0c1a40310\n",
+				"uri ftp://ftp.example.com/pub/\n",
+				"logType TCP_MISS\n",
+			},
+			expected: activeRequestsStats{
+				Total:    3,
+				LogTypes: map[string]float64{"TCP_TUNNEL": 1, "TCP_MISS": 2},
+				Ports:    map[string]float64{"443": 1, "8443": 1, "21": 1},
+				Ages:     ages(3, 0, 0, 0, 0),
+			},
+		},
+		{
+			name:  "还没有uri、logType和start行的连接",
+			lines: page[:7],
+			expected: activeRequestsStats{
+				Total:    1,
+				LogTypes: map[string]float64{"unknown": 1},
+				Ports:    map[string]float64{"unknown": 1},
+				Ages:     ages(1, 0, 0, 0, 0),
+			},
+		},
+		{
+			name: "SMP模式下各进程的请求",
+			lines: append(append(append([]string{"by kid1 {\n"}, page[:15]...), "} by kid1\n", "\n", "by kid2 {\n"),
+				append(page[30:], "\n", "} by kid2\n", "\n")...),
+			expected: activeRequestsStats{
+				Total:    2,
+				LogTypes: map[string]float64{"TCP_MISS": 1, "TCP_TUNNEL": 1},
+				Ports:    map[string]float64{"80": 1, "443": 1},
+				Ages:     ages(0, 0, 1, 0, 1),
+				Oldest:   300.5,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := newActiveRequestsParser()
+			for _, line := range tt.lines {
+				parser.parseLine(line)
+			}
+			assert.Equal(t, tt.expected, parser.result())
+		})
+	}
+}
+
+// 测试活动请求收集器的输出，没有请求时数量为0
+func TestSquidActiveRequestsCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{activeRequestsPage: readPage(t, "active_requests")}))
+
+	expected := `
+# HELP squid_active_requests Number of client requests in progress
+# TYPE squid_active_requests gauge
+squid_active_requests 3
+# HELP squid_active_requests_oldest_age_seconds Time since the oldest client request in progress started in seconds
+# TYPE squid_active_requests_oldest_age_seconds gauge
+squid_active_requests_oldest_age_seconds 300.5
+# HELP squid_active_requests_by_log_type Number of client requests in progress by current access log type
+# TYPE squid_active_requests_by_log_type gauge
+squid_active_requests_by_log_type{log_type="TCP_HIT"} 1
+squid_active_requests_by_log_type{log_type="TCP_MISS"} 1
+squid_active_requests_by_log_type{log_type="TCP_TUNNEL"} 1
+`
+	collector := NewSquidActiveRequestsCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_active_requests", "squid_active_requests_oldest_age_seconds", "squid_active_requests_by_log_type"))
+	assert.Equal(t, 5, testutil.CollectAndCount(collector, "squid_active_requests_by_age"))
+
+	empty := NewSquidActiveRequestsCollector(NewSnapshotSource(newPageClient(map[string][]string{activeRequestsPage: {}})))
+	assert.NoError(t, testutil.CollectAndCompare(empty, strings.NewReader(`
+# HELP squid_active_requests Number of client requests in progress
+# TYPE squid_active_requests gauge
+squid_active_requests 0
+`), "squid_active_requests"))
+}
+
+// 测试通过缓存管理器逐行读取大量活动请求
+func TestSquidActiveRequestsStreaming(t *testing.T) {
+	const requests = 5000
+	var page strings.Builder
+	for i := 0; i < requests; i++ {
+		fmt.Fprintf(&page, "Connection: 0x%x\nuri http://example.com:8080/upload\nlogType TCP_MISS\nstart 1700000000.000000 (%d.000000 seconds ago)\n\n", i, i%100)
+	}
+	host, port := newMgrPagesServer(t, map[string]string{"/squid-internal-mgr/active_requests": page.String()})
+	source := NewSnapshotSource(NewCacheObjectClient(&CacheObjectRequest{Hostname: host, Port: port, Transport: TransportHTTP}))
+	source.BeginScrape(context.Background())
+	defer source.EndScrape()
+
+	expected := `
+# HELP squid_active_requests_by_port Number of client requests in progress by destination port
+# TYPE squid_active_requests_by_port gauge
+squid_active_requests_by_port{port="8080"} 5000
+# HELP squid_active_requests_oldest_age_seconds Time since the oldest client request in progress started in seconds
+# TYPE squid_active_requests_oldest_age_seconds gauge
+squid_active_requests_oldest_age_seconds 99
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewSquidActiveRequestsCollector(source), strings.NewReader(expected),
+		"squid_active_requests_by_port", "squid_active_requests_oldest_age_seconds"))
+}
@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// averageWindows 把 mgr:5min 和 mgr:60min 页面映射为 window 标签的值
+var averageWindows = map[string]string{
+	"5min":  serviceTimeWindow5m,
+	"60min": serviceTimeWindow60m,
+}
+
+// 平均值的单位，由值后面的后缀决定
+const (
+	averageUnitRate      = "/sec"
+	averageUnitSeconds   = "seconds"
+	averageUnitPercent   = "%"
+	averageUnitTimestamp = "timestamp"
+)
+
+// averageValue 是 mgr:5min 或 mgr:60min 页面中的一项
+type averageValue struct {
+	Key   string
+	Value float64
+	Unit  string
+}
+
+// decodeAverageStrings 解析形如 "client_http.requests = 0.123456/sec" 的行，
+// 值后面带有日期的行（如 sample_start_time）为unix时间戳
+func decodeAverageStrings(line string) (averageValue, error) {
+	equal := strings.Index(line, "=")
+	if equal < 0 {
+		return averageValue{}, errors.New("average - could not parse line: " + line)
+	}
+	key := strings.TrimSpace(line[:equal])
+	fields := strings.Fields(line[equal+1:])
+	if key == "" || len(fields) == 0 {
+		return averageValue{}, errors.New("average - could not parse line: " + line)
+	}
+
+	value, unit := fields[0], ""
+	if idx := strings.IndexAny(value, "/%"); idx > 0 {
+		value, unit = value[:idx], value[idx:]
+	} else if len(fields) > 1 {
+		unit = fields[1]
+		if strings.HasPrefix(unit, "(") {
+			unit = averageUnitTimestamp
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return averageValue{}, fmt.Errorf("average - could not parse value of %s: %w", key, err)
+	}
+	return averageValue{Key: key, Value: number, Unit: unit}, nil
+}
+
+// averageValues 把页面解析为按键索引的数值
+func averageValues(lines []string) map[string]float64 {
+	values := map[string]float64{}
+	for _, value := range parseAverages(lines) {
+		values[value.Key] = value.Value
+	}
+	return values
+}
+
+// parseAverages 解析整个页面，无法解析的行被忽略
+func parseAverages(lines []string) []averageValue {
+	values := make([]averageValue, 0, len(lines))
+	for _, line := range lines {
+		if value, err := decodeAverageStrings(line); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// averageMetric 返回平均值对应的指标名和帮助文本，prefix 为指标名前缀，例如 squid_avg，
+// 其后的部分按计数器的规则生成，再根据单位添加后缀
+func averageMetric(prefix string, value averageValue) (string, string) {
+	name := counterForKey(value.Key).baseName(prefix)
+	switch value.Unit {
+	case averageUnitRate:
+		return name + "_per_second", fmt.Sprintf("Average rate of %s over the window, per second", value.Key)
+	case averageUnitSeconds:
+		return name + "_seconds", fmt.Sprintf("Average of %s over the window, in seconds", value.Key)
+	case averageUnitPercent:
+		return name + "_percent", fmt.Sprintf("Average of %s over the window, in percent", value.Key)
+	case averageUnitTimestamp:
+		return name + "_seconds", fmt.Sprintf("Value of %s for the window, in unix seconds", value.Key)
+	}
+	return name, fmt.Sprintf("Average of %s over the window", value.Key)
+}
+
+// GetSquidAverages 返回 mgr:5min 和 mgr:60min 的收集器，pages 为要请求的页面，为空时请求两个页面
+func GetSquidAverages(source *SnapshotSource, pages ...string) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidAveragesCollector(source, pages...)}
+}
+
+// SquidAveragesCollector 把Squid计算好的5分钟和60分钟平均值导出为带 window 标签的指标
+type SquidAveragesCollector struct {
+	source  *SnapshotSource
+	pages   []string
+	mu      sync.Mutex
+	metrics map[string]*perProcessMetric
+}
+
+// NewSquidAveragesCollector 创建新的平均值收集器，忽略未知的页面
+func NewSquidAveragesCollector(source *SnapshotSource, pages ...string) *SquidAveragesCollector {
+	if len(pages) == 0 {
+		pages = []string{"5min", "60min"}
+	}
+	collector := &SquidAveragesCollector{
+		source:  source,
+		metrics: map[string]*perProcessMetric{},
+	}
+	for _, page := range pages {
+		if _, ok := averageWindows[page]; ok {
+			collector.pages = append(collector.pages, page)
+		}
+	}
+	return collector
+}
+
+// metric 返回指标名对应的指标，首次出现时创建，两个窗口共用一个指标
+func (c *SquidAveragesCollector) metric(name, help string) *perProcessMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m, ok := c.metrics[name]; ok {
+		return m
+	}
+	m := newPerProcessMetric(name, help, prometheus.GaugeValue, "window")
+	c.metrics[name] = m
+	return m
+}
+
+// Describe 实现了Collector接口，指标随Squid的输出动态变化，不预先描述
+func (c *SquidAveragesCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidAveragesCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	for _, page := range c.pages {
+		c.collectPage(ch, snapshot, page)
+	}
+}
+
+// collectPage 输出一个页面中的全部平均值，SMP模式下同时输出各worker进程的值
+func (c *SquidAveragesCollector) collectPage(ch chan<- prometheus.Metric, snapshot *Snapshot, page string) {
+	lines, err := snapshot.Page(page)
+	if err != nil {
+		return
+	}
+
+	values := newPageValues(page, averageValues)
+	window := averageWindows[page]
+	seen := map[string]bool{}
+	for _, value := range parseAverages(lines) {
+		name, help := averageMetric("squid_avg", value)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		c.metric(name, help).collect(ch, snapshot, values.lookup(value.Key), window)
+	}
+}
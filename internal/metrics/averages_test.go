@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 返回固定管理页面的模拟客户端
+type pageClient struct {
+	pages map[string][]string
+	calls map[string]int
+}
+
+func newPageClient(pages map[string][]string) *pageClient {
+	return &pageClient{pages: pages, calls: map[string]int{}}
+}
+
+func (c *pageClient) GetCounters() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *pageClient) GetServiceTimes() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *pageClient) GetInfos() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *pageClient) GetPageContext(ctx context.Context, page string) ([]string, error) {
+	c.calls[page]++
+	lines, ok := c.pages[page]
+	if !ok {
+		return nil, fmt.Errorf("page %s not found", page)
+	}
+	return lines, nil
+}
+
+// readPage 读取 testdata 中保存的 Squid 管理页面，按行拆分并保留换行符
+func readPage(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name+".txt"))
+	if err != nil {
+		t.Fatalf("读取页面 %s 失败: %v", name, err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// 测试解析平均值页面的行
+func TestDecodeAverageStrings(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected averageValue
+	}{
+		{"client_http.requests = 0.123456/sec\n", averageValue{"client_http.requests", 0.123456, averageUnitRate}},
+		{"client_http.all_median_svc_time = 0.012000 seconds\n", averageValue{"client_http.all_median_svc_time", 0.012, averageUnitSeconds}},
+		{"cpu_usage = 0.041000%\n", averageValue{"cpu_usage", 0.041, averageUnitPercent}},
+		{"sample_start_time = 1700000000.5 (Tue, 14 Nov 2023 22:13:20 GMT)\n", averageValue{"sample_start_time", 1700000000.5, averageUnitTimestamp}},
+		{"median_select_fds = 2.000000\n", averageValue{"median_select_fds", 2, ""}},
+	}
+	for _, tt := range tests {
+		value, err := decodeAverageStrings(tt.line)
+		assert.NoError(t, err, tt.line)
+		assert.Equal(t, tt.expected, value, tt.line)
+	}
+
+	_, err := decodeAverageStrings("no equal sign\n")
+	assert.Error(t, err, "没有等号的行应返回错误")
+	_, err = decodeAverageStrings("client_http.requests = n/a\n")
+	assert.Error(t, err, "非数值应返回错误")
+}
+
+// 测试平均值收集器按窗口输出
+func TestSquidAveragesCollector(t *testing.T) {
+	client := newPageClient(map[string][]string{
+		"5min": {
+			"sample_start_time = 1700000000.0 (Tue, 14 Nov 2023 22:13:20 GMT)\n",
+			"client_http.requests = 12.5/sec\n",
+			"client_http.all_median_svc_time = 0.012 seconds\n",
+			"cpu_usage = 3.5%\n",
+		},
+		"60min": {
+			"client_http.requests = 10.0/sec\n",
+		},
+	})
+	source := NewSnapshotSource(client)
+
+	expected := `
+# HELP squid_avg_client_http_requests_per_second Average rate of client_http.requests over the window, per second
+# TYPE squid_avg_client_http_requests_per_second gauge
+squid_avg_client_http_requests_per_second{window="5m"} 12.5
+squid_avg_client_http_requests_per_second{window="60m"} 10
+# HELP squid_avg_client_http_all_median_svc_time_seconds Average of client_http.all_median_svc_time over the window, in seconds
+# TYPE squid_avg_client_http_all_median_svc_time_seconds gauge
+squid_avg_client_http_all_median_svc_time_seconds{window="5m"} 0.012
+# HELP squid_avg_cpu_usage_percent Average of cpu_usage over the window, in percent
+# TYPE squid_avg_cpu_usage_percent gauge
+squid_avg_cpu_usage_percent{window="5m"} 3.5
+# HELP squid_avg_sample_start_time_seconds Value of sample_start_time for the window, in unix seconds
+# TYPE squid_avg_sample_start_time_seconds gauge
+squid_avg_sample_start_time_seconds{window="5m"} 1.7e+09
+`
+	collector := NewSquidAveragesCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	assert.Equal(t, 1, client.calls["5min"], "同一快照内页面应只请求一次")
+
+	// 只请求指定的页面
+	client = newPageClient(map[string][]string{"60min": {"cpu_usage = 1%\n"}})
+	collector = NewSquidAveragesCollector(NewSnapshotSource(client), "60min")
+	assert.Equal(t, 1, testutil.CollectAndCount(collector))
+	assert.Zero(t, client.calls["5min"], "未选择的页面不应请求")
+}
@@ -4,6 +4,8 @@ package metrics
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -13,6 +15,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -36,38 +39,113 @@ type SquidClient interface {
 	GetInfos() ([]Counter, error)
 }
 
+// ContextSquidClient 是支持上下文的SquidClient，抓取的超时和取消会传递到连接的截止时间
+type ContextSquidClient interface {
+	SquidClient
+	GetCountersContext(ctx context.Context) ([]Counter, error)
+	GetServiceTimesContext(ctx context.Context) ([]Counter, error)
+	GetInfosContext(ctx context.Context) ([]Counter, error)
+}
+
+// PageSquidClient 是能够请求任意管理页面的SquidClient，页面的解析由各收集器完成
+type PageSquidClient interface {
+	SquidClient
+	GetPageContext(ctx context.Context, page string) ([]string, error)
+}
+
+// StreamSquidClient 是能够逐行处理管理页面的SquidClient，用于行数很多、不适合整页缓存的页面
+type StreamSquidClient interface {
+	SquidClient
+	StreamPageContext(ctx context.Context, page string, handle func(line string)) error
+}
+
 // CacheObjectClient 保存Squid缓存对象管理器的信息
 type CacheObjectClient struct {
 	ch              connectionHandler
 	basicAuthString string
 	headers         []string
+	hostname        string
+	port            int
+	transport       string
+	mgrPath         string
+	// tls 为true时通过TLS连接 https_port，请求行使用 https:// 形式
+	tls         bool
+	readTimeout time.Duration
+
+	mu       sync.Mutex
+	detected string
 }
 
 type connectionHandler interface {
-	connect() (net.Conn, error)
+	connect(ctx context.Context) (net.Conn, error)
 }
 
 type connectionHandlerImpl struct {
-	hostname string
-	port     int
+	hostname    string
+	port        int
+	dialTimeout time.Duration
+	tlsConfig   *tls.Config
+	tlsErr      error
+	tlsState    *tlsState
 }
 
 type CacheObjectRequest struct {
-	Hostname string
-	Port     int
-	Login    string
-	Password string
-	Headers  []string
+	Hostname  string
+	Port      int
+	Login     string
+	Password  string
+	Headers   []string
+	Transport string
+	MgrPath   string
+	// TLS 不为空时通过TLS连接缓存管理器
+	TLS *TLSOptions
+	// DialTimeout 建立连接（含TLS握手）的超时时间，为0时使用默认值
+	DialTimeout time.Duration
+	// ReadTimeout 发送请求并读取完整响应的超时时间，为0时使用默认值
+	ReadTimeout time.Duration
 }
 
 const (
-	requestProtocol = "GET cache_object://localhost/%s HTTP/1.0"
-	timeout         = 10 * time.Second
+	requestProtocol     = "GET cache_object://localhost/%s HTTP/1.0"
+	httpRequestProtocol = "GET http://%s%s%s HTTP/1.0"
+	// httpsRequestProtocol 用于 https_port，请求行中的协议与连接一致
+	httpsRequestProtocol = "GET https://%s%s%s HTTP/1.0"
+	timeout              = 10 * time.Second
 )
 
-// 连接到指定的主机和端口
-func (c *connectionHandlerImpl) connect() (net.Conn, error) {
-	return net.DialTimeout("tcp", fmt.Sprintf("%s:%d", c.hostname, c.port), timeout)
+// 连接到指定的主机和端口，配置了TLS时完成握手并记录结果。
+// TCP连接失败时没有进行握手，不记录握手结果，错误只体现在 squid_up 中
+func (c *connectionHandlerImpl) connect(ctx context.Context) (net.Conn, error) {
+	address := net.JoinHostPort(c.hostname, strconv.Itoa(c.port))
+	// 超时时间包括TLS握手
+	ctx, cancel := context.WithTimeout(ctx, c.dialTimeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil || c.tlsState == nil {
+		return conn, err
+	}
+
+	if c.tlsErr != nil {
+		conn.Close()
+		c.tlsState.record(nil, c.tlsErr)
+		return nil, c.tlsErr
+	}
+
+	config := c.tlsConfig
+	if config.ServerName == "" {
+		// 与 tls.Dialer 相同，未指定SNI时使用连接的主机名
+		config = config.Clone()
+		config.ServerName = c.hostname
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		c.tlsState.record(nil, err)
+		return nil, err
+	}
+	c.tlsState.record(tlsConn, nil)
+	return tlsConn, nil
 }
 
 // 创建基本认证字符串
@@ -81,31 +159,111 @@ func buildBasicAuthString(login string, password string) string {
 
 // NewCacheObjectClient 初始化一个新的缓存客户端
 func NewCacheObjectClient(cor *CacheObjectRequest) *CacheObjectClient {
+	transport := cor.Transport
+	if !ValidTransport(transport) {
+		transport = TransportAuto
+	}
+	handler := &connectionHandlerImpl{
+		hostname:    cor.Hostname,
+		port:        cor.Port,
+		dialTimeout: cor.DialTimeout,
+	}
+	if handler.dialTimeout <= 0 {
+		handler.dialTimeout = timeout
+	}
+	readTimeout := cor.ReadTimeout
+	if readTimeout <= 0 {
+		readTimeout = timeout
+	}
+	if cor.TLS != nil {
+		// TLS配置错误在每次连接时返回，使其体现在 squid_up 和握手指标中
+		handler.tlsConfig, handler.tlsErr = buildTLSConfig(cor.TLS)
+		handler.tlsState = &tlsState{status: TLSStatus{Enabled: true}}
+	}
+
 	return &CacheObjectClient{
-		&connectionHandlerImpl{
-			cor.Hostname,
-			cor.Port,
-		},
-		buildBasicAuthString(cor.Login, cor.Password),
-		cor.Headers,
+		ch:              handler,
+		basicAuthString: buildBasicAuthString(cor.Login, cor.Password),
+		headers:         cor.Headers,
+		hostname:        cor.Hostname,
+		port:            cor.Port,
+		transport:       transport,
+		mgrPath:         normalizeMgrPath(cor.MgrPath),
+		tls:             cor.TLS != nil,
+		readTimeout:     readTimeout,
 	}
 }
 
-// 从Squid读取数据
-func (c *CacheObjectClient) readFromSquid(endpoint string) (*bufio.Reader, error) {
-	conn, err := c.ch.connect()
+// TLSStatus 返回最近一次TLS握手的结果，未启用TLS时 Enabled 为false
+func (c *CacheObjectClient) TLSStatus() TLSStatus {
+	if handler, ok := c.ch.(*connectionHandlerImpl); ok && handler.tlsState != nil {
+		return handler.tlsState.get()
+	}
+	return TLSStatus{}
+}
+
+// mgrResponse 是管理页面的响应体，关闭时同时关闭底层连接
+type mgrResponse struct {
+	io.Reader
+	conn net.Conn
+	stop func() bool
+}
+
+func (r *mgrResponse) Close() error {
+	r.stop()
+	return r.conn.Close()
+}
+
+// 从Squid读取数据，自动模式下当Squid拒绝当前请求方式时尝试另一种方式
+func (c *CacheObjectClient) readFromSquid(ctx context.Context, endpoint string) (io.ReadCloser, error) {
+	var lastErr error
+	for _, transport := range c.transportOrder() {
+		body, err := c.requestMgr(ctx, transport, endpoint)
+		if err == nil {
+			if c.transport == TransportAuto {
+				c.rememberTransport(transport)
+			}
+			return body, nil
+		}
+		lastErr = err
+
+		// 只有Squid明确拒绝时才回退，连接错误换一种方式也不会成功
+		var statusErr *mgrStatusError
+		if !errors.As(err, &statusErr) {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// requestMgr 使用指定的请求方式请求管理页面
+func (c *CacheObjectClient) requestMgr(ctx context.Context, transport, endpoint string) (io.ReadCloser, error) {
+	conn, err := c.ch.connect(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// 读写截止时间取读取超时和抓取上下文截止时间中较早的一个，
+	// 上下文提前取消时立即让阻塞的读写返回
+	deadline := time.Now().Add(c.readTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
 	// 不要在这里关闭连接，而是在HTTP读取响应后，由调用者关闭
 
 	// 构建完整的HTTP请求
-	rBody := append(c.headers, []string{
-		fmt.Sprintf(requestProtocol, endpoint),
-		"Host: localhost",
+	requestLine, hostHeader := c.requestHead(transport, endpoint)
+	rBody := append([]string{}, c.headers...)
+	rBody = append(rBody,
+		requestLine,
+		hostHeader,
 		"User-Agent: squidclient/3.5.12",
-	}...)
+	)
 
 	// 添加认证头
 	if c.basicAuthString != "" {
@@ -123,56 +281,75 @@ func (c *CacheObjectClient) readFromSquid(endpoint string) (*bufio.Reader, error
 	// 读取HTTP响应
 	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
 	if err != nil {
+		stop()
 		conn.Close()
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
 	if resp.StatusCode != 200 {
+		stop()
 		conn.Close()
-		return nil, fmt.Errorf("Non success code %d while fetching metrics", resp.StatusCode)
+		return nil, &mgrStatusError{code: resp.StatusCode}
 	}
 
 	// 返回响应体的读取器
-	return bufio.NewReader(resp.Body), nil
+	return &mgrResponse{Reader: resp.Body, conn: conn, stop: stop}, nil
 }
 
-// 读取响应行
-func readLines(reader *bufio.Reader, lines chan<- string) {
+// contextError 上下文已结束时返回上下文的错误，便于区分抓取超时和其他网络错误
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// fetchLines 请求管理页面并逐行处理响应，读取中断时返回错误而不是部分数据
+func (c *CacheObjectClient) fetchLines(ctx context.Context, endpoint string, handle func(line string)) error {
+	body, err := c.readFromSquid(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	reader := bufio.NewReader(body)
 	for {
 		line, err := reader.ReadString('\n')
-
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			log.Printf("error reading from the bufio.Reader: %v", err)
-			break
+			return contextError(ctx, err)
 		}
-
-		lines <- line
+		handle(line)
 	}
-	close(lines)
+	return nil
 }
 
 // GetCounters 从squid缓存管理器获取计数器
 func (c *CacheObjectClient) GetCounters() ([]Counter, error) {
-	var counters []Counter
+	return c.GetCountersContext(context.Background())
+}
 
-	reader, err := c.readFromSquid("counters")
-	if err != nil {
-		return nil, fmt.Errorf("error getting counters: %v", err)
-	}
+// GetCountersContext 在指定上下文中从squid缓存管理器获取计数器
+func (c *CacheObjectClient) GetCountersContext(ctx context.Context) ([]Counter, error) {
+	return c.getCounters(ctx, "")
+}
 
-	lines := make(chan string)
-	go readLines(reader, lines)
+// getCounters 获取计数器，prefix 为页面前缀，例如SMP模式下的 kid1/
+func (c *CacheObjectClient) getCounters(ctx context.Context, prefix string) ([]Counter, error) {
+	var counters []Counter
 
-	for line := range lines {
+	err := c.fetchLines(ctx, prefix+"counters", func(line string) {
 		counter, err := decodeCounterStrings(line)
 		if err != nil {
 			log.Println(err)
 		} else {
 			counters = append(counters, counter)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting counters: %w", err)
 	}
 
 	return counters, nil
@@ -180,23 +357,28 @@ func (c *CacheObjectClient) GetCounters() ([]Counter, error) {
 
 // GetServiceTimes 从squid缓存管理器获取服务时间
 func (c *CacheObjectClient) GetServiceTimes() ([]Counter, error) {
-	var serviceTimes []Counter
+	return c.GetServiceTimesContext(context.Background())
+}
 
-	reader, err := c.readFromSquid("service_times")
-	if err != nil {
-		return nil, fmt.Errorf("error getting service times: %v", err)
-	}
+// GetServiceTimesContext 在指定上下文中从squid缓存管理器获取服务时间
+func (c *CacheObjectClient) GetServiceTimesContext(ctx context.Context) ([]Counter, error) {
+	return c.getServiceTimes(ctx, "")
+}
 
-	lines := make(chan string)
-	go readLines(reader, lines)
+// getServiceTimes 获取服务时间，prefix 为页面前缀，例如SMP模式下的 kid1/
+func (c *CacheObjectClient) getServiceTimes(ctx context.Context, prefix string) ([]Counter, error) {
+	var serviceTimes []Counter
 
-	for line := range lines {
+	err := c.fetchLines(ctx, prefix+"service_times", func(line string) {
 		serviceTime, err := decodeServiceTimeStrings(line)
 		if err != nil {
 			log.Println(err)
 		} else if serviceTime.Key != "" {
 			serviceTimes = append(serviceTimes, serviceTime)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting service times: %w", err)
 	}
 
 	return serviceTimes, nil
@@ -204,53 +386,135 @@ func (c *CacheObjectClient) GetServiceTimes() ([]Counter, error) {
 
 // GetInfos 从squid缓存管理器获取信息
 func (c *CacheObjectClient) GetInfos() ([]Counter, error) {
-	var infos []Counter
+	return c.GetInfosContext(context.Background())
+}
 
-	reader, err := c.readFromSquid("info")
+// GetInfosContext 在指定上下文中从squid缓存管理器获取信息
+func (c *CacheObjectClient) GetInfosContext(ctx context.Context) ([]Counter, error) {
+	return c.getInfos(ctx, "")
+}
+
+// getInfos 获取信息，prefix 为页面前缀，例如SMP模式下的 kid1/
+func (c *CacheObjectClient) getInfos(ctx context.Context, prefix string) ([]Counter, error) {
+	parser := newInfoParser()
+	err := c.fetchLines(ctx, prefix+"info", parser.parseLine)
 	if err != nil {
-		return nil, fmt.Errorf("error getting info: %v", err)
+		return nil, fmt.Errorf("error getting info: %w", err)
 	}
+	return parser.result(), nil
+}
 
-	lines := make(chan string)
-	go readLines(reader, lines)
+// GetPageContext 在指定上下文中请求任意管理页面，例如 5min，返回页面的全部行
+func (c *CacheObjectClient) GetPageContext(ctx context.Context, page string) ([]string, error) {
+	return c.getPage(ctx, "", page)
+}
 
-	var infoVarLabels Counter
-	infoVarLabels.Key = "squid_info"
-	infoVarLabels.Value = 1
+// StreamPageContext 在指定上下文中请求管理页面，每读到一行就交给 handle 处理，不保存整个页面
+func (c *CacheObjectClient) StreamPageContext(ctx context.Context, page string, handle func(line string)) error {
+	if err := c.fetchLines(ctx, page, handle); err != nil {
+		return fmt.Errorf("error getting %s: %w", page, err)
+	}
+	return nil
+}
 
-	for line := range lines {
-		info, err := decodeInfoStrings(line)
-		if err != nil {
-			log.Println(err)
-		} else if len(info.VarLabels) > 0 {
-			if info.VarLabels[0].Key == "5min" {
-				var infoAvg5 Counter
-				var infoAvg60 Counter
+// getPage 请求管理页面，prefix 为页面前缀，例如SMP模式下的 kid1/
+func (c *CacheObjectClient) getPage(ctx context.Context, prefix, page string) ([]string, error) {
+	var lines []string
+	err := c.fetchLines(ctx, prefix+page, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %w", page, err)
+	}
+	return lines, nil
+}
 
-				infoAvg5.Key = info.Key + "_" + info.VarLabels[0].Key
-				infoAvg60.Key = info.Key + "_" + info.VarLabels[1].Key
+const (
+	// infoLabelsKey 是保存版本、编译信息和服务名的信息项的键
+	infoLabelsKey = "squid_info"
+	// medianInfoPrefix 是 Median Service Times 段落中各项的键前缀
+	medianInfoPrefix = "Median_"
+)
 
-				if value, err := strconv.ParseFloat(info.VarLabels[0].Value, 64); err == nil {
-					infoAvg5.Value = value
-					infos = append(infos, infoAvg5)
-				}
-				if value, err := strconv.ParseFloat(info.VarLabels[1].Value, 64); err == nil {
-					infoAvg60.Value = value
-					infos = append(infos, infoAvg60)
-				}
-			} else {
-				infoVarLabels.VarLabels = append(infoVarLabels.VarLabels, info.VarLabels[0])
-			}
-		} else if info.Key != "" {
-			infos = append(infos, info)
+// infoParser 按段落解析 mgr:info 页面，段落标题形如 "Cache information for squid:"
+type infoParser struct {
+	median bool
+	infos  []Counter
+	labels Counter
+}
+
+func newInfoParser() *infoParser {
+	return &infoParser{labels: Counter{Key: infoLabelsKey, Value: 1}}
+}
+
+// parseLine 解析一行，5分钟和60分钟窗口的值拆分为以 _5min、_60min 结尾的两项，
+// Median Service Times 段落中的项加上 Median_ 前缀
+func (p *infoParser) parseLine(line string) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasSuffix(trimmed, ":") {
+		p.median = strings.HasPrefix(trimmed, "Median Service Times")
+		return
+	}
+
+	if p.median {
+		if key, values, ok := decodeMedianInfoStrings(trimmed); ok {
+			p.appendWindows(medianInfoPrefix+key, values[0], values[1])
+			return
 		}
 	}
 
-	if len(infoVarLabels.VarLabels) > 0 {
-		infos = append(infos, infoVarLabels)
+	info, err := decodeInfoStrings(line)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(info.VarLabels) == 0 {
+		if info.Key != "" {
+			p.infos = append(p.infos, info)
+		}
+		return
+	}
+	if info.VarLabels[0].Key == "5min" {
+		p.appendWindows(info.Key, info.VarLabels[0].Value, info.VarLabels[1].Value)
+		return
 	}
+	p.labels.VarLabels = append(p.labels.VarLabels, info.VarLabels[0])
+}
 
-	return infos, nil
+// appendWindows 添加5分钟和60分钟窗口的两项，无法解析的值被忽略
+func (p *infoParser) appendWindows(key, avg5, avg60 string) {
+	if value, err := strconv.ParseFloat(avg5, 64); err == nil {
+		p.infos = append(p.infos, Counter{Key: key + "_5min", Value: value})
+	}
+	if value, err := strconv.ParseFloat(avg60, 64); err == nil {
+		p.infos = append(p.infos, Counter{Key: key + "_60min", Value: value})
+	}
+}
+
+// result 返回解析结果，版本等字符串信息作为 squid_info 项的标签
+func (p *infoParser) result() []Counter {
+	infos := p.infos
+	if len(p.labels.VarLabels) > 0 {
+		infos = append(infos, p.labels)
+	}
+	return infos
+}
+
+// decodeMedianInfoStrings 解析形如 "HTTP Requests (All):   0.00865  0.01035" 的中位数服务时间
+func decodeMedianInfoStrings(line string) (string, [2]string, bool) {
+	idx := strings.LastIndex(line, ":")
+	if idx <= 0 {
+		return "", [2]string{}, false
+	}
+	fields := strings.Fields(line[idx+1:])
+	if len(fields) != 2 {
+		return "", [2]string{}, false
+	}
+	key := strings.TrimSpace(line[:idx])
+	key = strings.Replace(key, " ", "_", -1)
+	key = strings.Replace(key, "(", "", -1)
+	key = strings.Replace(key, ")", "", -1)
+	return key, [2]string{fields[0], fields[1]}, true
 }
 
 // 解析counters响应
@@ -291,17 +555,26 @@ func decodeServiceTimeStrings(line string) (Counter, error) {
 			key = strings.Replace(key, "(", "", -1)
 			key = strings.Replace(key, ")", "", -1)
 
+			// 百分位行形如 "5%   0.00865   0.01035"，依次为5分钟和60分钟窗口的值
+			var varLabels []VarLabel
 			if equalTwo := strings.Index(value, "%"); equalTwo >= 0 {
 				if keyTwo := strings.TrimSpace(value[:equalTwo]); len(keyTwo) > 0 {
 					if len(value) > equalTwo {
-						value = strings.Split(strings.TrimSpace(value[equalTwo+1:]), " ")[0]
+						fields := strings.Fields(value[equalTwo+1:])
+						value = ""
+						if len(fields) > 0 {
+							value = fields[0]
+						}
+						if len(fields) > 1 {
+							varLabels = append(varLabels, VarLabel{Key: "60min", Value: fields[1]})
+						}
 					}
 					key = key + "_" + keyTwo
 				}
 			}
 
 			if value, err := strconv.ParseFloat(value, 64); err == nil {
-				return Counter{Key: key, Value: value}, nil
+				return Counter{Key: key, Value: value, VarLabels: varLabels}, nil
 			}
 		}
 	}
@@ -343,8 +616,12 @@ func decodeInfoStrings(line string) (Counter, error) {
 				infoCounter.Key = key
 				infoCounter.VarLabels = append(infoCounter.VarLabels, infoVarLabel)
 				return infoCounter, nil
-			} else if key == "Start_Time" || key == "Current_Time" { // discart this metrics
-				return Counter{}, nil
+			} else if key == "Start_Time" || key == "Current_Time" { // RFC 1123 time, exported as a unix timestamp
+				t, err := time.Parse(time.RFC1123, value)
+				if err != nil {
+					return Counter{}, fmt.Errorf("info - could not parse time %q: %w", value, err)
+				}
+				return Counter{Key: key, Value: float64(t.Unix())}, nil
 			}
 
 			// Remove additional information in value metric
@@ -402,23 +679,3 @@ func decodeInfoStrings(line string) (Counter, error) {
 
 	return Counter{}, errors.New("info - could not parse line: " + line)
 }
-
-// 全局配置参数，由main函数或其他初始化代码设置
-var (
-	GlobalHostname string   = "localhost"
-	GlobalPort     int      = 3128
-	GlobalLogin    string   = ""
-	GlobalPassword string   = ""
-	GlobalHeaders  []string = []string{}
-)
-
-// 使用全局配置创建一个CacheObjectClient
-func GetGlobalClient() *CacheObjectClient {
-	return NewCacheObjectClient(&CacheObjectRequest{
-		Hostname: GlobalHostname,
-		Port:     GlobalPort,
-		Login:    GlobalLogin,
-		Password: GlobalPassword,
-		Headers:  GlobalHeaders,
-	})
-}
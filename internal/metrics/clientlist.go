@@ -0,0 +1,274 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// clientListPage 是每个客户端的请求统计所在的管理页面
+const clientListPage = "client_list"
+
+// clientListOther 是超出 Top 的客户端合并后的 client 标签值
+const clientListOther = "other"
+
+// ClientListOptions 限制 mgr:client_list 导出的客户端数量。Networks 中的网段（例如squid.conf中
+// localnet 的网段）内的客户端按网段合并，合并后按请求数导出前 Top 个，其余合并为 other，Top 不大于0时导出全部
+type ClientListOptions struct {
+	Top      int
+	Networks []string
+}
+
+// clientResult 是一个客户端某种结果代码的请求数
+type clientResult struct {
+	Protocol string
+	Result   string
+	Count    float64
+}
+
+// clientStats 是 mgr:client_list 中一个客户端（或合并后的一组客户端）的统计信息
+type clientStats struct {
+	Address      string
+	Connections  float64
+	HTTPRequests float64
+	ICPRequests  float64
+	Results      []clientResult
+}
+
+// requests 返回客户端的HTTP和ICP请求总数，用于排序
+func (c clientStats) requests() float64 {
+	return c.HTTPRequests + c.ICPRequests
+}
+
+// add 把另一个客户端的统计信息合并到当前客户端
+func (c *clientStats) add(other clientStats) {
+	c.Connections += other.Connections
+	c.HTTPRequests += other.HTTPRequests
+	c.ICPRequests += other.ICPRequests
+	for _, result := range other.Results {
+		merged := false
+		for i := range c.Results {
+			if c.Results[i].Protocol == result.Protocol && c.Results[i].Result == result.Result {
+				c.Results[i].Count += result.Count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			c.Results = append(c.Results, result)
+		}
+	}
+}
+
+// parseClientList 解析 mgr:client_list，SMP模式下每个进程各有一个客户端列表，
+// 同一客户端出现在多个进程中时累加
+func parseClientList(lines []string) []clientStats {
+	var clients []clientStats
+	index := map[string]int{}
+	for _, section := range kidSections(lines) {
+		for _, client := range parseClientSection(section) {
+			if i, ok := index[client.Address]; ok {
+				clients[i].add(client)
+				continue
+			}
+			index[client.Address] = len(clients)
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// parseClientSection 解析一个进程的客户端列表，每个客户端以 "Address: 10.0.0.5" 行开始，
+// "HTTP Requests 1234" 之后的 "TCP_MISS 1000 81%" 等行为该协议按结果代码的请求数，遇到 TOTALS 时停止
+func parseClientSection(lines []string) []clientStats {
+	var clients []clientStats
+	var current *clientStats
+	protocol := ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "TOTALS" {
+			break
+		}
+		if address, ok := strings.CutPrefix(trimmed, "Address:"); ok {
+			clients = append(clients, clientStats{Address: strings.TrimSpace(address)})
+			current = &clients[len(clients)-1]
+			protocol = ""
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if connections, ok := strings.CutPrefix(trimmed, "Currently established connections:"); ok {
+			current.Connections, _ = strconv.ParseFloat(strings.TrimSpace(connections), 64)
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		if len(fields) == 3 && fields[1] == "Requests" {
+			count, _ := strconv.ParseFloat(fields[2], 64)
+			switch fields[0] {
+			case "HTTP":
+				protocol = "http"
+				current.HTTPRequests = count
+			case "ICP":
+				protocol = "icp"
+				current.ICPRequests = count
+			}
+			continue
+		}
+		if protocol == "" || len(fields) < 2 {
+			continue
+		}
+		if count, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			current.Results = append(current.Results, clientResult{Protocol: protocol, Result: fields[0], Count: count})
+		}
+	}
+	return clients
+}
+
+// parseClientNetworks 解析网段，单个地址视为只包含该地址的网段，无法解析的值被忽略
+func parseClientNetworks(values []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, value := range values {
+		// squid.conf中一行 acl 可以列出多个网段
+		for _, field := range strings.Fields(value) {
+			if _, network, err := net.ParseCIDR(field); err == nil {
+				networks = append(networks, network)
+				continue
+			}
+			if ip := net.ParseIP(field); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+		}
+	}
+	return networks
+}
+
+// groupClients 按网段合并客户端，不在任何网段内的客户端保持不变，结果按请求数从多到少排列
+func groupClients(clients []clientStats, networks []*net.IPNet) []clientStats {
+	groups := map[string]*clientStats{}
+	var order []string
+	for _, client := range clients {
+		key := client.Address
+		if ip := net.ParseIP(client.Address); ip != nil {
+			for _, network := range networks {
+				if network.Contains(ip) {
+					key = network.String()
+					break
+				}
+			}
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &clientStats{Address: key}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.add(client)
+	}
+
+	grouped := make([]clientStats, 0, len(order))
+	for _, key := range order {
+		grouped = append(grouped, *groups[key])
+	}
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return grouped[i].requests() > grouped[j].requests()
+	})
+	return grouped
+}
+
+// selectClients 保留请求数最多的 top 个客户端，其余合并为 other
+func selectClients(clients []clientStats, top int) []clientStats {
+	if top <= 0 || len(clients) <= top {
+		return clients
+	}
+	selected := append([]clientStats{}, clients[:top]...)
+	other := clientStats{Address: clientListOther}
+	for _, client := range clients[top:] {
+		other.add(client)
+	}
+	return append(selected, other)
+}
+
+// GetSquidClientList 返回 mgr:client_list 的收集器
+func GetSquidClientList(source *SnapshotSource, opts ClientListOptions) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidClientListCollector(source, opts)}
+}
+
+// SquidClientListCollector 按客户端导出连接数、HTTP和ICP请求数及按结果代码的请求数，标签为 client。
+// 前 Top 个客户端和 other 的成员每次抓取重新排序，请求数不是单调递增的，因此全部导出为gauge
+type SquidClientListCollector struct {
+	source       *SnapshotSource
+	top          int
+	networks     []*net.IPNet
+	clients      *prometheus.Desc
+	connections  *prometheus.Desc
+	httpRequests *prometheus.Desc
+	icpRequests  *prometheus.Desc
+	results      *prometheus.Desc
+}
+
+// NewSquidClientListCollector 创建新的客户端收集器
+func NewSquidClientListCollector(source *SnapshotSource, opts ClientListOptions) *SquidClientListCollector {
+	return &SquidClientListCollector{
+		source:   source,
+		top:      opts.Top,
+		networks: parseClientNetworks(opts.Networks),
+		clients: prometheus.NewDesc("squid_client_list_clients",
+			"Number of clients known to Squid before grouping", nil, nil),
+		connections: prometheus.NewDesc("squid_client_list_connections",
+			"Number of established connections from the client", []string{"client"}, nil),
+		httpRequests: prometheus.NewDesc("squid_client_list_http_requests",
+			"Number of HTTP requests from the client recorded by Squid", []string{"client"}, nil),
+		icpRequests: prometheus.NewDesc("squid_client_list_icp_requests",
+			"Number of ICP requests from the client recorded by Squid", []string{"client"}, nil),
+		results: prometheus.NewDesc("squid_client_list_results",
+			"Number of requests from the client recorded by Squid by protocol and result code", []string{"client", "protocol", "result"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidClientListCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.clients
+	ch <- c.connections
+	ch <- c.httpRequests
+	ch <- c.icpRequests
+	ch <- c.results
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidClientListCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(clientListPage)
+	if err != nil {
+		return
+	}
+	clients := parseClientList(lines)
+	ch <- prometheus.MustNewConstMetric(c.clients, prometheus.GaugeValue, float64(len(clients)))
+
+	for _, client := range selectClients(groupClients(clients, c.networks), c.top) {
+		ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, client.Connections, client.Address)
+		ch <- prometheus.MustNewConstMetric(c.httpRequests, prometheus.GaugeValue, client.HTTPRequests, client.Address)
+		ch <- prometheus.MustNewConstMetric(c.icpRequests, prometheus.GaugeValue, client.ICPRequests, client.Address)
+
+		seen := map[string]bool{}
+		for _, result := range client.Results {
+			key := result.Protocol + " " + result.Result
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ch <- prometheus.MustNewConstMetric(c.results, prometheus.GaugeValue, result.Count,
+				client.Address, result.Protocol, result.Result)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析客户端统计信息
+func TestParseClientList(t *testing.T) {
+	page := readPage(t, "client_list")
+	ws5 := clientStats{
+		Address: "10.0.0.5", Connections: 2, HTTPRequests: 150, ICPRequests: 3,
+		Results: []clientResult{
+			{Protocol: "icp", Result: "UDP_HIT", Count: 1},
+			{Protocol: "icp", Result: "UDP_MISS", Count: 2},
+			{Protocol: "http", Result: "TCP_HIT", Count: 40},
+			{Protocol: "http", Result: "TCP_MISS", Count: 100},
+			{Protocol: "http", Result: "TCP_TUNNEL", Count: 10},
+		},
+	}
+	ws6 := clientStats{
+		Address: "10.0.0.6", HTTPRequests: 20,
+		Results: []clientResult{
+			{Protocol: "http", Result: "TCP_MISS", Count: 15},
+			{Protocol: "http", Result: "TCP_DENIED", Count: 5},
+		},
+	}
+	ipv6 := clientStats{
+		Address: "2001:db8::5", Connections: 1, HTTPRequests: 7,
+		Results: []clientResult{{Protocol: "http", Result: "TCP_MEM_HIT", Count: 7}},
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []clientStats
+	}{
+		{
+			name:     "IPv4和IPv6客户端的ICP和HTTP请求",
+			lines:    page,
+			expected: []clientStats{ws5, ws6, ipv6},
+		},
+		{
+			name: "没有请求的客户端",
+			lines: []string{
+				page[0],
+				"Address: 10.0.0.7\n",
+				"Currently established connections: 1\n",
+				"    ICP  Requests 0\n",
+				"    HTTP Requests 0\n",
+				"\n",
+				"TOTALS\n",
+			},
+			expected: []clientStats{{Address: "10.0.0.7", Connections: 1}},
+		},
+		{
+			name:     "TOTALS之后的行被忽略",
+			lines:    append(append([]string{}, page[len(page)-3:]...), page[1], page[3], page[7]),
+			expected: nil,
+		},
+		{
+			name: "SMP模式下累加各进程中的同一客户端",
+			lines: append(append(append([]string{"by kid1 {\n"}, page...), "} by kid1\n", "\n", "by kid2 {\n"),
+				page[0], page[1], page[3], page[7], "        TCP_MISS                 150 100%\n",
+				"\n", page[len(page)-3], "} by kid2\n", "\n"),
+			expected: []clientStats{
+				{
+					Address: "10.0.0.5", Connections: 4, HTTPRequests: 300, ICPRequests: 3,
+					Results: []clientResult{
+						{Protocol: "icp", Result: "UDP_HIT", Count: 1},
+						{Protocol: "icp", Result: "UDP_MISS", Count: 2},
+						{Protocol: "http", Result: "TCP_HIT", Count: 40},
+						{Protocol: "http", Result: "TCP_MISS", Count: 250},
+						{Protocol: "http", Result: "TCP_TUNNEL", Count: 10},
+					},
+				},
+				ws6,
+				ipv6,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseClientList(tt.lines))
+		})
+	}
+}
+
+// 测试按网段合并和按请求数保留前N个客户端
+func TestSelectClients(t *testing.T) {
+	clients := parseClientList(readPage(t, "client_list"))
+
+	tests := []struct {
+		name      string
+		networks  []string
+		top       int
+		addresses []string
+		requests  []float64
+	}{
+		{
+			name:      "其余客户端合并为other",
+			top:       1,
+			addresses: []string{"10.0.0.5", "other"},
+			requests:  []float64{150, 27},
+		},
+		{
+			name:      "不限制数量",
+			addresses: []string{"10.0.0.5", "10.0.0.6", "2001:db8::5"},
+			requests:  []float64{150, 20, 7},
+		},
+		{
+			name:      "按网段合并",
+			networks:  []string{"10.0.0.0/8", "fc00::/7 2001:db8::/32", "not-a-network"},
+			addresses: []string{"10.0.0.0/8", "2001:db8::/32"},
+			requests:  []float64{170, 7},
+		},
+		{
+			name:      "单个地址视为网段",
+			networks:  []string{"10.0.0.6"},
+			top:       2,
+			addresses: []string{"10.0.0.5", "10.0.0.6/32", "other"},
+			requests:  []float64{150, 20, 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := selectClients(groupClients(clients, parseClientNetworks(tt.networks)), tt.top)
+			var addresses []string
+			var requests []float64
+			for _, client := range selected {
+				addresses = append(addresses, client.Address)
+				requests = append(requests, client.HTTPRequests)
+			}
+			assert.Equal(t, tt.addresses, addresses)
+			assert.Equal(t, tt.requests, requests)
+		})
+	}
+
+	grouped := groupClients(clients, parseClientNetworks([]string{"10.0.0.0/8"}))
+	assert.Equal(t, 2.0, grouped[0].Connections)
+	assert.Contains(t, grouped[0].Results, clientResult{Protocol: "http", Result: "TCP_MISS", Count: 115}, "合并时按结果代码累加")
+}
+
+// 测试客户端收集器的输出
+func TestSquidClientListCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{clientListPage: readPage(t, "client_list")}))
+
+	expected := `
+# HELP squid_client_list_clients Number of clients known to Squid before grouping
+# TYPE squid_client_list_clients gauge
+squid_client_list_clients 3
+# HELP squid_client_list_http_requests Number of HTTP requests from the client recorded by Squid
+# TYPE squid_client_list_http_requests gauge
+squid_client_list_http_requests{client="10.0.0.0/8"} 170
+squid_client_list_http_requests{client="other"} 7
+`
+	collector := NewSquidClientListCollector(source, ClientListOptions{Top: 1, Networks: []string{"10.0.0.0/8"}})
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_client_list_clients", "squid_client_list_http_requests"))
+	assert.Equal(t, 7, testutil.CollectAndCount(collector, "squid_client_list_results"))
+}
+
+// 测试两次抓取之间排名变化时，客户端进出前N个，other 随之增减，因此请求数按gauge导出
+func TestSquidClientListRankingChange(t *testing.T) {
+	page := func(first, second float64) []string {
+		return strings.SplitAfter(fmt.Sprintf(`Cache Clients:
+Address: 192.168.1.10
+Currently established connections: 1
+    ICP  Requests 0
+    HTTP Requests %[1]v
+        TCP_MISS                 %[1]v 100%%
+
+Address: 192.168.1.11
+Currently established connections: 1
+    ICP  Requests 0
+    HTTP Requests %[2]v
+        TCP_MISS                 %[2]v 100%%
+
+Address: 192.168.1.12
+Currently established connections: 0
+    ICP  Requests 0
+    HTTP Requests 10
+        TCP_MISS                 10 100%%
+
+TOTALS
+`, first, second), "\n")
+	}
+	pages := map[string][]string{}
+	source := NewSnapshotSource(newPageClient(pages))
+	collector := NewSquidClientListCollector(source, ClientListOptions{Top: 1})
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+	}{
+		{
+			name:  "第一个客户端领先",
+			lines: page(100, 50),
+			expected: `
+# HELP squid_client_list_http_requests Number of HTTP requests from the client recorded by Squid
+# TYPE squid_client_list_http_requests gauge
+squid_client_list_http_requests{client="192.168.1.10"} 100
+squid_client_list_http_requests{client="other"} 60
+`,
+		},
+		{
+			name:  "第二个客户端超过第一个",
+			lines: page(110, 200),
+			expected: `
+# HELP squid_client_list_http_requests Number of HTTP requests from the client recorded by Squid
+# TYPE squid_client_list_http_requests gauge
+squid_client_list_http_requests{client="192.168.1.11"} 200
+squid_client_list_http_requests{client="other"} 120
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages[clientListPage] = tt.lines
+			source.BeginScrape(context.Background())
+			assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(tt.expected),
+				"squid_client_list_http_requests"))
+		})
+	}
+}
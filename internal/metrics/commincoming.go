@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// commIncomingLoops 把 mgr:comm_epoll_incoming 和 mgr:comm_select_incoming 映射为 loop 标签的值，
+// Squid只提供与编译时选择的I/O循环对应的页面
+var commIncomingLoops = map[string]string{
+	"comm_epoll_incoming":  "epoll",
+	"comm_select_incoming": "select",
+}
+
+// commIncomingBuckets 是每次调用处理的文件描述符数或消息数直方图的桶上限
+var commIncomingBuckets = []float64{0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024}
+
+// commIncomingStats 是一个 comm_*_incoming 页面的解析结果
+type commIncomingStats struct {
+	Loops float64
+	// Intervals 为 select 循环检查各类套接字的间隔，键为 udp、dns、tcp
+	Intervals map[string]float64
+	// Histograms 为每种套接字每次调用处理的数量的直方图，键为 fds、icp、dns、http
+	Histograms map[string]map[int]float64
+}
+
+// parseCommIncoming 解析 comm_*_incoming 页面。直方图以 "Histogram of returned filedescriptors"
+// 或 "ICP Messages handled per comm_select_udp_incoming() call:" 等行开始，其后的行形如 "        1\t     1234"。
+// SMP模式下每个进程各有一份统计，循环次数和直方图累加，检查间隔取最大值
+func parseCommIncoming(lines []string) commIncomingStats {
+	stats := commIncomingStats{Intervals: map[string]float64{}, Histograms: map[string]map[int]float64{}}
+	histogram := ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "Total number of ") && strings.Contains(trimmed, "loops:"):
+			fields := strings.Fields(trimmed)
+			if loops, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil {
+				stats.Loops += loops
+			}
+			continue
+		case strings.HasPrefix(trimmed, "Current incoming_"):
+			// 形如 "Current incoming_udp_interval: 3"
+			key, value, _ := strings.Cut(strings.TrimPrefix(trimmed, "Current incoming_"), ":")
+			if interval, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				key = strings.TrimSuffix(key, "_interval")
+				stats.Intervals[key] = max(stats.Intervals[key], interval)
+			}
+			continue
+		case strings.HasPrefix(trimmed, "Histogram of returned filedescriptors"):
+			histogram = "fds"
+			continue
+		case strings.Contains(trimmed, " Messages handled per "):
+			histogram = strings.ToLower(strings.Fields(trimmed)[0])
+			continue
+		}
+		if histogram == "" {
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if count, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			if stats.Histograms[histogram] == nil {
+				stats.Histograms[histogram] = map[int]float64{}
+			}
+			stats.Histograms[histogram][value] += count
+		}
+	}
+	return stats
+}
+
+// GetSquidCommIncoming 返回 comm_*_incoming 页面的收集器，pages 为要请求的页面
+func GetSquidCommIncoming(source *SnapshotSource, pages ...string) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidCommIncomingCollector(source, pages...)}
+}
+
+// SquidCommIncomingCollector 把I/O循环每次调用处理的文件描述符数或消息数导出为直方图，并导出循环次数和检查间隔
+type SquidCommIncomingCollector struct {
+	source    *SnapshotSource
+	pages     []string
+	perCall   *prometheus.Desc
+	loops     *prometheus.Desc
+	intervals *prometheus.Desc
+}
+
+// NewSquidCommIncomingCollector 创建新的I/O循环收集器，忽略未知的页面
+func NewSquidCommIncomingCollector(source *SnapshotSource, pages ...string) *SquidCommIncomingCollector {
+	collector := &SquidCommIncomingCollector{
+		source: source,
+		perCall: prometheus.NewDesc("squid_comm_incoming_per_call",
+			"Number of file descriptors or messages handled per call of the incoming socket loop", []string{"loop", "type"}, nil),
+		loops: prometheus.NewDesc("squid_comm_loops_total",
+			"Total number of I/O loop iterations", []string{"loop"}, nil),
+		intervals: prometheus.NewDesc("squid_comm_incoming_interval",
+			"Current interval between checks of the incoming sockets of the type", []string{"loop", "type"}, nil),
+	}
+	for _, page := range pages {
+		if _, ok := commIncomingLoops[page]; ok {
+			collector.pages = append(collector.pages, page)
+		}
+	}
+	return collector
+}
+
+// Describe 实现了Collector接口
+func (c *SquidCommIncomingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.perCall
+	ch <- c.loops
+	ch <- c.intervals
+}
+
+// Collect实现了Collector接口，用于采集指标，Squid未提供的页面不输出任何指标
+func (c *SquidCommIncomingCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	for _, page := range c.pages {
+		lines, err := snapshot.Page(page)
+		if err != nil {
+			continue
+		}
+		loop := commIncomingLoops[page]
+		stats := parseCommIncoming(lines)
+
+		if stats.Loops > 0 {
+			ch <- prometheus.MustNewConstMetric(c.loops, prometheus.CounterValue, stats.Loops, loop)
+		}
+		for key, interval := range stats.Intervals {
+			ch <- prometheus.MustNewConstMetric(c.intervals, prometheus.GaugeValue, interval, loop, key)
+		}
+		types := make([]string, 0, len(stats.Histograms))
+		for key := range stats.Histograms {
+			types = append(types, key)
+		}
+		sort.Strings(types)
+		for _, key := range types {
+			buckets, count, sum := countsHistogram(stats.Histograms[key], commIncomingBuckets)
+			ch <- prometheus.MustNewConstHistogram(c.perCall, count, sum, buckets, loop, key)
+		}
+	}
+}
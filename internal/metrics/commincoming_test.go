@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析I/O循环页面，Squid不输出计数为0的直方图行
+func TestParseCommIncoming(t *testing.T) {
+	epoll := readPage(t, "comm_epoll_incoming")
+	sel := readPage(t, "comm_select_incoming")
+	kid := func(n string, lines ...string) []string {
+		return append(append([]string{"by kid" + n + " {\n"}, lines...), "} by kid"+n+"\n", "\n")
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected commIncomingStats
+	}{
+		{
+			name:  "epoll循环的直方图",
+			lines: epoll,
+			expected: commIncomingStats{
+				Loops:      58211,
+				Intervals:  map[string]float64{},
+				Histograms: map[string]map[int]float64{"fds": {0: 51002, 1: 6120, 2: 901, 3: 150, 5: 38}},
+			},
+		},
+		{
+			name:  "select循环的检查间隔和没有行的ICP直方图",
+			lines: sel,
+			expected: commIncomingStats{
+				Intervals: map[string]float64{"udp": 256, "dns": 192, "tcp": 128},
+				Histograms: map[string]map[int]float64{
+					"dns":  {1: 410, 2: 12},
+					"http": {1: 1820, 2: 44, 4: 3},
+				},
+			},
+		},
+		{
+			name:  "SMP模式下累加循环次数和直方图",
+			lines: append(kid("1", epoll...), kid("2", epoll[0], epoll[1], "        0\t    49000\n", "        7\t        2\n")...),
+			expected: commIncomingStats{
+				Loops:      116422,
+				Intervals:  map[string]float64{},
+				Histograms: map[string]map[int]float64{"fds": {0: 100002, 1: 6120, 2: 901, 3: 150, 5: 38, 7: 2}},
+			},
+		},
+		{
+			name: "SMP模式下检查间隔取最大值",
+			lines: append(kid("1", sel[:3]...), kid("2", "Current incoming_udp_interval: 16\n",
+				"Current incoming_dns_interval: 512\n", "Current incoming_tcp_interval: 128\n")...),
+			expected: commIncomingStats{
+				Intervals:  map[string]float64{"udp": 256, "dns": 512, "tcp": 128},
+				Histograms: map[string]map[int]float64{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseCommIncoming(tt.lines))
+		})
+	}
+}
+
+// 测试I/O循环收集器只输出Squid提供的页面
+func TestSquidCommIncomingCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{"comm_epoll_incoming": readPage(t, "comm_epoll_incoming")}))
+
+	expected := `
+# HELP squid_comm_loops_total Total number of I/O loop iterations
+# TYPE squid_comm_loops_total counter
+squid_comm_loops_total{loop="epoll"} 58211
+`
+	collector := NewSquidCommIncomingCollector(source, "comm_epoll_incoming", "comm_select_incoming")
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "squid_comm_loops_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "squid_comm_incoming_per_call"))
+	assert.Equal(t, 0, testutil.CollectAndCount(collector, "squid_comm_incoming_interval"))
+
+	source = NewSnapshotSource(newPageClient(map[string][]string{"comm_select_incoming": readPage(t, "comm_select_incoming")}))
+	expected = `
+# HELP squid_comm_incoming_interval Current interval between checks of the incoming sockets of the type
+# TYPE squid_comm_incoming_interval gauge
+squid_comm_incoming_interval{loop="select",type="dns"} 192
+squid_comm_incoming_interval{loop="select",type="tcp"} 128
+squid_comm_incoming_interval{loop="select",type="udp"} 256
+`
+	collector = NewSquidCommIncomingCollector(source, "comm_epoll_incoming", "comm_select_incoming")
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "squid_comm_incoming_interval"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "squid_comm_incoming_per_call"), "没有数据的ICP直方图不输出")
+}
+
+// 测试SMP模式下各进程的循环次数累加
+func TestSquidCommIncomingCollectorSMP(t *testing.T) {
+	page := readPage(t, "comm_epoll_incoming")
+	var lines []string
+	for _, kid := range []string{"1", "2"} {
+		lines = append(lines, "by kid"+kid+" {\n")
+		lines = append(lines, page...)
+		lines = append(lines, "} by kid"+kid+"\n", "\n")
+	}
+	source := NewSnapshotSource(newPageClient(map[string][]string{"comm_epoll_incoming": lines}))
+
+	expected := `
+# HELP squid_comm_loops_total Total number of I/O loop iterations
+# TYPE squid_comm_loops_total counter
+squid_comm_loops_total{loop="epoll"} 116422
+`
+	collector := NewSquidCommIncomingCollector(source, "comm_epoll_incoming", "comm_select_incoming")
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "squid_comm_loops_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "squid_comm_incoming_per_call"))
+}
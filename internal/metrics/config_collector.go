@@ -34,6 +34,8 @@ type SquidConfigCollector struct {
 
 	// 配置摘要指标
 	configSummary *prometheus.Desc
+	// 每个cache_dir一个序列，可与 squid_storedir_* 按 path 和 type 关联
+	cacheDirs *prometheus.Desc
 }
 
 // NewSquidConfigCollector 创建新的squid配置指标收集器
@@ -110,6 +112,13 @@ func NewSquidConfigCollector(configPath string) *SquidConfigCollector {
 			[]string{"config_file", "http_port", "cache_dir", "coredump_dir"},
 			nil,
 		),
+
+		cacheDirs: prometheus.NewDesc(
+			"squid_config_cache_dir",
+			"cache_dir configured in squid.conf, the value is always 1",
+			[]string{"path", "type"},
+			nil,
+		),
 	}
 
 	// 启动配置文件监控
@@ -131,6 +140,7 @@ func (c *SquidConfigCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.refreshPatterns.Describe(ch)
 	c.acls.Describe(ch)
 	ch <- c.configSummary
+	ch <- c.cacheDirs
 }
 
 // Collect 实现prometheus.Collector接口
@@ -219,6 +229,15 @@ func (c *SquidConfigCollector) Collect(ch chan<- prometheus.Metric) {
 		cacheDir,
 		coredumpDir,
 	)
+
+	seen := map[string]bool{}
+	for _, dir := range configData.CacheDirs {
+		if seen[dir.Path] {
+			continue
+		}
+		seen[dir.Path] = true
+		ch <- prometheus.MustNewConstMetric(c.cacheDirs, prometheus.GaugeValue, 1.0, dir.Path, dir.Type)
+	}
 }
 
 // extractCacheDirPath 从cache_dir配置中提取目录路径
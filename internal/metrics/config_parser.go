@@ -12,15 +12,25 @@ import (
 
 // SquidConfigData 表示解析后的squid配置数据
 type SquidConfigData struct {
-	HttpPort        int      `json:"http_port"`
-	CacheDir        string   `json:"cache_dir"`
-	CoreDumpDir     string   `json:"coredump_dir"`
-	LocalNetworks   []string `json:"local_networks"`
-	SafePorts       []int    `json:"safe_ports"`
-	SSLPorts        []int    `json:"ssl_ports"`
-	AccessRules     []string `json:"access_rules"`
-	RefreshPatterns []string `json:"refresh_patterns"`
-	ACLs            []ACL    `json:"acls"`
+	HttpPort int    `json:"http_port"`
+	CacheDir string `json:"cache_dir"`
+	// CacheDirs 为全部 cache_dir 配置，CacheDir 只保存最后一个
+	CacheDirs       []CacheDirConfig `json:"cache_dirs"`
+	CoreDumpDir     string           `json:"coredump_dir"`
+	LocalNetworks   []string         `json:"local_networks"`
+	SafePorts       []int            `json:"safe_ports"`
+	SSLPorts        []int            `json:"ssl_ports"`
+	AccessRules     []string         `json:"access_rules"`
+	RefreshPatterns []string         `json:"refresh_patterns"`
+	ACLs            []ACL            `json:"acls"`
+	// Workers 为SMP模式的worker数量，未配置时为0
+	Workers int `json:"workers"`
+}
+
+// CacheDirConfig 表示一个 cache_dir 配置项
+type CacheDirConfig struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
 }
 
 // ACL 表示访问控制列表项
@@ -117,6 +127,11 @@ func (p *SquidConfigParser) parseLine(line string, config *SquidConfigData) erro
 		return p.parseRefreshPattern(line, config)
 	}
 
+	// 解析workers
+	if strings.HasPrefix(line, "workers ") {
+		return p.parseWorkers(line, config)
+	}
+
 	return nil
 }
 
@@ -191,6 +206,9 @@ func (p *SquidConfigParser) parseCacheDir(line string, config *SquidConfigData)
 		// 保存完整的cache_dir配置
 		config.CacheDir = strings.Join(parts[1:], " ")
 	}
+	if len(parts) >= 3 {
+		config.CacheDirs = append(config.CacheDirs, CacheDirConfig{Type: parts[1], Path: parts[2]})
+	}
 	return nil
 }
 
@@ -215,6 +233,22 @@ func (p *SquidConfigParser) parseRefreshPattern(line string, config *SquidConfig
 	return nil
 }
 
+// parseWorkers 解析workers配置
+func (p *SquidConfigParser) parseWorkers(line string, config *SquidConfigData) error {
+	parts := strings.Fields(line)
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid workers format: %s", line)
+	}
+
+	workers, err := strconv.Atoi(parts[1])
+	if err != nil || workers < 0 {
+		return fmt.Errorf("invalid workers number: %s", parts[1])
+	}
+
+	config.Workers = workers
+	return nil
+}
+
 // parsePorts 解析端口配置，支持单个端口和端口范围
 func (p *SquidConfigParser) parsePorts(portStr string) ([]int, error) {
 	var ports []int
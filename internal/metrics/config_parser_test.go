@@ -38,6 +38,8 @@ http_access deny all
 # Squid normally listens to port 3128
 http_port 3128
 
+workers 4
+
 # Uncomment and adjust the following to add a disk cache directory.
 cache_dir ufs /var/spool/squid 100 16 256
 
@@ -81,6 +83,10 @@ refresh_pattern .               0       20%     4320
 		t.Errorf("Expected cache_dir 'ufs /var/spool/squid 100 16 256', got '%s'", config.CacheDir)
 	}
 
+	if len(config.CacheDirs) != 1 || config.CacheDirs[0] != (CacheDirConfig{Type: "ufs", Path: "/var/spool/squid"}) {
+		t.Errorf("Expected cache_dirs [ufs /var/spool/squid], got %v", config.CacheDirs)
+	}
+
 	if config.CoreDumpDir != "/var/spool/squid" {
 		t.Errorf("Expected coredump_dir '/var/spool/squid', got '%s'", config.CoreDumpDir)
 	}
@@ -115,6 +121,11 @@ refresh_pattern .               0       20%     4320
 	if len(config.ACLs) < 5 {
 		t.Errorf("Expected at least 5 ACLs, got %d", len(config.ACLs))
 	}
+
+	// 验证workers
+	if config.Workers != 4 {
+		t.Errorf("Expected 4 workers, got %d", config.Workers)
+	}
 }
 
 func TestSquidConfigParser_ParsePorts(t *testing.T) {
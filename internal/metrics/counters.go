@@ -3,7 +3,9 @@
 package metrics
 
 import (
-	"fmt"
+	"strings"
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -13,102 +15,205 @@ type squidCounter struct {
 	Counter     string
 	Suffix      string
 	Description string
+	Gauge       bool
+}
+
+// key 返回计数器在 mgr:counters 中的键
+func (c squidCounter) key() string {
+	if c.Section == "" {
+		return c.Counter
+	}
+	return c.Section + "." + c.Counter
+}
+
+// fqName 返回计数器的指标名
+func (c squidCounter) fqName() string {
+	name := c.baseName("squid")
+	if c.Suffix != "" {
+		name += "_" + c.Suffix
+	}
+	return name
 }
 
-// Squid计数器指标列表
+// baseName 返回不带后缀的指标名，由前缀、清理后的段名和计数器名组成，
+// mgr:counters、mgr:5min 和 mgr:utilization 共用此规则
+func (c squidCounter) baseName(prefix string) string {
+	return prometheus.BuildFQName(prefix, replaceNonAlphanumeric(c.Section), replaceNonAlphanumeric(c.Counter))
+}
+
+// Squid计数器的HELP目录，决定已知键的指标名和类型，未列出的键按 counterForKey 的规则导出
 var squidCounters = []squidCounter{
-	{"client_http", "requests", "total", "The total number of client requests"},
-	{"client_http", "hits", "total", "The total number of client cache hits"},
-	{"client_http", "errors", "total", "The total number of client http errors"},
-	{"client_http", "kbytes_in", "kbytes_total", "The total number of client kbytes received"},
-	{"client_http", "kbytes_out", "kbytes_total", "The total number of client kbytes transferred"},
-	{"client_http", "hit_kbytes_out", "bytes_total", "The total number of client kbytes cache hit"},
-
-	{"server.http", "requests", "total", "The total number of server http requests"},
-	{"server.http", "errors", "total", "The total number of server http errors"},
-	{"server.http", "kbytes_in", "kbytes_total", "The total number of server http kbytes received"},
-	{"server.http", "kbytes_out", "kbytes_total", "The total number of server http kbytes transferred"},
-
-	{"server.all", "requests", "total", "The total number of server all requests"},
-	{"server.all", "errors", "total", "The total number of server all errors"},
-	{"server.all", "kbytes_in", "kbytes_total", "The total number of server kbytes received"},
-	{"server.all", "kbytes_out", "kbytes_total", "The total number of server kbytes transferred"},
-
-	{"server.ftp", "requests", "total", "The total number of server ftp requests"},
-	{"server.ftp", "errors", "total", "The total number of server ftp errors"},
-	{"server.ftp", "kbytes_in", "kbytes_total", "The total number of server ftp kbytes received"},
-	{"server.ftp", "kbytes_out", "kbytes_total", "The total number of server ftp kbytes transferred"},
-
-	{"server.other", "requests", "total", "The total number of server other requests"},
-	{"server.other", "errors", "total", "The total number of server other errors"},
-	{"server.other", "kbytes_in", "kbytes_total", "The total number of server other kbytes received"},
-	{"server.other", "kbytes_out", "kbytes_total", "The total number of server other kbytes transferred"},
-
-	{"swap", "ins", "total", "The number of objects read from disk"},
-	{"swap", "outs", "total", "The number of objects saved to disk"},
-	{"swap", "files_cleaned", "total", "The number of orphaned cache files removed by the periodic cleanup procedure"},
+	{"", "sample_time", "seconds", "Time the counters were sampled, in unix seconds", true},
+
+	{"client_http", "requests", "total", "The total number of client requests", false},
+	{"client_http", "hits", "total", "The total number of client cache hits", false},
+	{"client_http", "errors", "total", "The total number of client http errors", false},
+	{"client_http", "kbytes_in", "kbytes_total", "The total number of client kbytes received", false},
+	{"client_http", "kbytes_out", "kbytes_total", "The total number of client kbytes transferred", false},
+	{"client_http", "hit_kbytes_out", "bytes_total", "The total number of client kbytes cache hit", false},
+	{"client_http", "all_median_svc_time", "seconds", "Median service time of all client requests", true},
+	{"client_http", "miss_median_svc_time", "seconds", "Median service time of cache misses", true},
+	{"client_http", "nm_median_svc_time", "seconds", "Median service time of not-modified replies", true},
+	{"client_http", "nh_median_svc_time", "seconds", "Median service time of near hits", true},
+	{"client_http", "hit_median_svc_time", "seconds", "Median service time of cache hits", true},
+
+	{"server.http", "requests", "total", "The total number of server http requests", false},
+	{"server.http", "errors", "total", "The total number of server http errors", false},
+	{"server.http", "kbytes_in", "kbytes_total", "The total number of server http kbytes received", false},
+	{"server.http", "kbytes_out", "kbytes_total", "The total number of server http kbytes transferred", false},
+
+	{"server.all", "requests", "total", "The total number of server all requests", false},
+	{"server.all", "errors", "total", "The total number of server all errors", false},
+	{"server.all", "kbytes_in", "kbytes_total", "The total number of server kbytes received", false},
+	{"server.all", "kbytes_out", "kbytes_total", "The total number of server kbytes transferred", false},
+
+	{"server.ftp", "requests", "total", "The total number of server ftp requests", false},
+	{"server.ftp", "errors", "total", "The total number of server ftp errors", false},
+	{"server.ftp", "kbytes_in", "kbytes_total", "The total number of server ftp kbytes received", false},
+	{"server.ftp", "kbytes_out", "kbytes_total", "The total number of server ftp kbytes transferred", false},
+
+	{"server.other", "requests", "total", "The total number of server other requests", false},
+	{"server.other", "errors", "total", "The total number of server other errors", false},
+	{"server.other", "kbytes_in", "kbytes_total", "The total number of server other kbytes received", false},
+	{"server.other", "kbytes_out", "kbytes_total", "The total number of server other kbytes transferred", false},
+
+	{"icp", "pkts_sent", "total", "The total number of ICP packets sent", false},
+	{"icp", "pkts_recv", "total", "The total number of ICP packets received", false},
+	{"icp", "queries_sent", "total", "The total number of ICP queries sent", false},
+	{"icp", "replies_sent", "total", "The total number of ICP replies sent", false},
+	{"icp", "queries_recv", "total", "The total number of ICP queries received", false},
+	{"icp", "replies_recv", "total", "The total number of ICP replies received", false},
+	{"icp", "query_timeouts", "total", "The total number of ICP queries that timed out", false},
+	{"icp", "replies_queued", "total", "The total number of ICP replies queued", false},
+	{"icp", "kbytes_sent", "total", "The total number of ICP kbytes sent", false},
+	{"icp", "kbytes_recv", "total", "The total number of ICP kbytes received", false},
+	{"icp", "q_kbytes_sent", "total", "The total number of ICP query kbytes sent", false},
+	{"icp", "r_kbytes_sent", "total", "The total number of ICP reply kbytes sent", false},
+	{"icp", "q_kbytes_recv", "total", "The total number of ICP query kbytes received", false},
+	{"icp", "r_kbytes_recv", "total", "The total number of ICP reply kbytes received", false},
+	{"icp", "times_used", "total", "The number of times ICP was used to select a peer", false},
+	{"icp", "query_median_svc_time", "seconds", "Median service time of ICP queries", true},
+	{"icp", "reply_median_svc_time", "seconds", "Median service time of ICP replies", true},
+
+	{"htcp", "pkts_sent", "total", "The total number of HTCP packets sent", false},
+	{"htcp", "pkts_recv", "total", "The total number of HTCP packets received", false},
+	{"htcp", "queries_sent", "total", "The total number of HTCP queries sent", false},
+	{"htcp", "replies_sent", "total", "The total number of HTCP replies sent", false},
+	{"htcp", "queries_recv", "total", "The total number of HTCP queries received", false},
+	{"htcp", "replies_recv", "total", "The total number of HTCP replies received", false},
+	{"htcp", "kbytes_sent", "total", "The total number of HTCP kbytes sent", false},
+	{"htcp", "kbytes_recv", "total", "The total number of HTCP kbytes received", false},
+
+	{"cd", "times_used", "total", "The number of times cache digests were used to select a peer", false},
+	{"cd", "msgs_sent", "total", "The total number of cache digest messages sent", false},
+	{"cd", "msgs_recv", "total", "The total number of cache digest messages received", false},
+	{"cd", "memory", "kbytes", "Memory used by peer cache digests in kbytes", true},
+	{"cd", "local_memory", "kbytes", "Memory used by the local cache digest in kbytes", true},
+	{"cd", "kbytes_sent", "total", "The total number of cache digest kbytes sent", false},
+	{"cd", "kbytes_recv", "total", "The total number of cache digest kbytes received", false},
+
+	{"unlink", "requests", "total", "The total number of requests given to unlinkd", false},
+	{"", "page_faults", "total", "The total number of page faults with physical i/o", false},
+	{"", "select_loops", "total", "The total number of select loops", false},
+	{"", "cpu_time", "seconds_total", "CPU time consumed by squid in seconds", false},
+	{"", "wall_time", "seconds", "Wall clock time covered by the counters sample in seconds", true},
+
+	{"swap", "outs", "total", "The number of objects saved to disk", false},
+	{"swap", "ins", "total", "The number of objects read from disk", false},
+	{"swap", "files_cleaned", "total", "The number of orphaned cache files removed by the periodic cleanup procedure", false},
+	{"", "aborted_requests", "total", "The total number of aborted client requests", false},
 }
 
-// GetSquidCounters 返回所有Squid计数器指标
-func GetSquidCounters() []prometheus.Collector {
-	counters := []prometheus.Collector{}
+// squidCounterCatalogue 按 mgr:counters 的键索引计数器目录
+var squidCounterCatalogue = func() map[string]squidCounter {
+	catalogue := make(map[string]squidCounter, len(squidCounters))
 	for _, counter := range squidCounters {
-		counters = append(counters,
-			NewSquidCounter(counter.Section, counter.Counter, counter.Suffix, counter.Description))
+		catalogue[counter.key()] = counter
+	}
+	return catalogue
+}()
+
+// counterForKey 返回键对应的计数器定义。目录中没有的键（例如新版本Squid新增的键）
+// 使用清理后的键名，中位数和服务时间视为gauge，其余视为counter并添加 _total 后缀
+func counterForKey(key string) squidCounter {
+	if counter, ok := squidCounterCatalogue[key]; ok {
+		return counter
 	}
-	return counters
+
+	counter := squidCounter{Counter: key, Description: "Squid counter " + key}
+	if strings.Contains(key, "median") || strings.Contains(key, "svc_time") {
+		counter.Gauge = true
+	} else {
+		counter.Suffix = "total"
+	}
+	return counter
+}
+
+// GetSquidCounters 返回Squid计数器指标收集器，mgr:counters 中的每个数值键都会被导出
+func GetSquidCounters(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidCountersCollector(source)}
+}
+
+// SquidCountersCollector 动态导出 mgr:counters 中的全部数值键
+type SquidCountersCollector struct {
+	source  *SnapshotSource
+	mu      sync.Mutex
+	metrics map[string]*perProcessMetric
 }
 
-// SquidCounter 是用于存储Squid计数器的指标
-type SquidCounter struct {
-	*baseMetrics
-	section string
-	counter string
+// NewSquidCountersCollector 创建新的计数器收集器
+func NewSquidCountersCollector(source *SnapshotSource) *SquidCountersCollector {
+	return &SquidCountersCollector{
+		source:  source,
+		metrics: map[string]*perProcessMetric{},
+	}
 }
 
-// NewSquidCounter创建一个新的SquidCounter实例
-func NewSquidCounter(section, counter, suffix, help string) *SquidCounter {
-	fqname := prometheus.BuildFQName("squid",
-		replaceNonAlphanumeric(section),
-		counter+"_"+suffix)
+// metric 返回键对应的指标，首次出现时创建
+func (c *SquidCountersCollector) metric(key string) (*perProcessMetric, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return &SquidCounter{
-		baseMetrics: NewMetrics(fqname, help, []string{}),
-		section:     section,
-		counter:     counter,
+	counter := counterForKey(key)
+	name := counter.fqName()
+	if m, ok := c.metrics[key]; ok {
+		return m, name
 	}
+
+	valueType := prometheus.CounterValue
+	if counter.Gauge {
+		valueType = prometheus.GaugeValue
+	}
+	m := newPerProcessMetric(name, counter.Description, valueType)
+	c.metrics[key] = m
+	return m, name
 }
 
-// Describe 实现了Collector接口
-func (sc *SquidCounter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- sc.baseMetrics.desc
+// Describe 实现了Collector接口，指标随Squid的输出动态变化，不预先描述
+func (c *SquidCountersCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 // Collect实现了Collector接口，用于采集指标
-func (sc *SquidCounter) Collect(ch chan<- prometheus.Metric) {
-	// 创建一个客户端连接Squid服务器
-	client := NewCacheObjectClient(&CacheObjectRequest{
-		Hostname: GlobalHostname,
-		Port:     GlobalPort,
-		Login:    GlobalLogin,
-		Password: GlobalPassword,
-		Headers:  GlobalHeaders,
-	})
-
-	counters, err := client.GetCounters()
+func (c *SquidCountersCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	counters, err := snapshot.Counters()
 	if err != nil {
-		// 连接失败，记录错误并返回
 		return
 	}
 
-	// 查找匹配的指标
-	key := fmt.Sprintf("%s.%s", sc.section, sc.counter)
+	// 不同的键清理后可能得到相同的指标名，只输出第一个
+	seen := make(map[string]bool, len(counters))
 	for _, counter := range counters {
-		if counter.Key == key {
-			// 找到匹配的指标，使用实际数据，注意这里用CounterValue而不是GaugeValue
-			ch <- prometheus.MustNewConstMetric(sc.baseMetrics.desc, prometheus.CounterValue, counter.Value)
-			return
+		key := counter.Key
+		m, name := c.metric(key)
+		if seen[name] {
+			continue
 		}
+		seen[name] = true
+
+		m.collect(ch, snapshot, func(snapshot *Snapshot) (float64, bool) {
+			return snapshot.Counter(key)
+		})
 	}
 }
 
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -395,3 +396,64 @@ func TestSpecialFormatCounters(t *testing.T) {
 		})
 	}
 }
+
+// 测试计数器目录和未知键的命名与类型
+func TestCounterForKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		name  string
+		gauge bool
+	}{
+		{"client_http.requests", "squid_client_http_requests_total", false},
+		{"client_http.kbytes_in", "squid_client_http_kbytes_in_kbytes_total", false},
+		{"server.http.errors", "squid_server_http_errors_total", false},
+		{"client_http.all_median_svc_time", "squid_client_http_all_median_svc_time_seconds", true},
+		{"cpu_time", "squid_cpu_time_seconds_total", false},
+		{"cd.memory", "squid_cd_memory_kbytes", true},
+		{"sample_time", "squid_sample_time_seconds", true},
+		{"future.new-counter", "squid_future_new_counter_total", false},
+		{"future.median_time", "squid_future_median_time", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			counter := counterForKey(tt.key)
+			assert.Equal(t, tt.name, counter.fqName(), "指标名应匹配")
+			assert.Equal(t, tt.gauge, counter.Gauge, "类型应匹配")
+			assert.NotEmpty(t, counter.Description, "应有HELP文本")
+		})
+	}
+}
+
+// 测试动态导出 mgr:counters 中的全部数值键
+func TestSquidCountersCollectorDynamic(t *testing.T) {
+	client := &MockCacheObjectClient{mockData: []string{
+		"sample_time = 1700000000.5 (Tue, 14 Nov 2023 22:13:20 GMT)",
+		"client_http.requests = 10",
+		"icp.pkts_sent = 3",
+		"client_http.all_median_svc_time = 0.012 seconds",
+		"future.counter = 7",
+		"future_counter = 8",
+	}}
+	source := NewSnapshotSource(client)
+
+	expected := `
+# HELP squid_client_http_all_median_svc_time_seconds Median service time of all client requests
+# TYPE squid_client_http_all_median_svc_time_seconds gauge
+squid_client_http_all_median_svc_time_seconds 0.012
+# HELP squid_client_http_requests_total The total number of client requests
+# TYPE squid_client_http_requests_total counter
+squid_client_http_requests_total 10
+# HELP squid_future_counter_total Squid counter future.counter
+# TYPE squid_future_counter_total counter
+squid_future_counter_total 7
+# HELP squid_icp_pkts_sent_total The total number of ICP packets sent
+# TYPE squid_icp_pkts_sent_total counter
+squid_icp_pkts_sent_total 3
+# HELP squid_sample_time_seconds Time the counters were sampled, in unix seconds
+# TYPE squid_sample_time_seconds gauge
+squid_sample_time_seconds 1.7000000005e+09
+`
+	collector := NewSquidCountersCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
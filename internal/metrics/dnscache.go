@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dnsCachePage 描述 mgr:ipcache 或 mgr:fqdncache 页面
+type dnsCachePage struct {
+	// page 为管理页面名称，prefix 为统计项的前缀，例如 "IPcache Hits: 4000" 中的 IPcache
+	page   string
+	prefix string
+	// subsystem 为指标名中的子系统，例如 squid_ipcache_hits_total 中的 ipcache
+	subsystem string
+}
+
+var (
+	ipCachePage   = dnsCachePage{page: "ipcache", prefix: "IPcache", subsystem: "ipcache"}
+	fqdnCachePage = dnsCachePage{page: "fqdncache", prefix: "FQDNcache", subsystem: "fqdncache"}
+)
+
+// parseDNSCacheStats 解析页面开头的统计项，键为去掉前缀后的名称，例如 "Negative Hits"。
+// SMP模式下聚合页面按进程分块返回，累加各进程的统计项
+func (p dnsCachePage) parseDNSCacheStats(lines []string) map[string]float64 {
+	stats := map[string]float64{}
+	for _, section := range kidSections(lines) {
+		p.parseDNSCacheSection(section, stats)
+	}
+	return stats
+}
+
+// parseDNSCacheSection 解析一个进程的统计项并累加到 stats 中，遇到缓存内容表格时停止。
+// ipcache 的表头以 Hostname 开始，fqdncache 的表头以 Address 开始
+func (p dnsCachePage) parseDNSCacheSection(lines []string, stats map[string]float64) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, "Contents:") || strings.HasPrefix(line, "Hostname") || strings.HasPrefix(line, "Address") {
+			break
+		}
+		if !strings.HasPrefix(line, p.prefix+" ") {
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(line[idx+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		stats[strings.TrimSpace(line[len(p.prefix):idx])] += value
+	}
+}
+
+// metric 返回统计项对应的指标名、帮助文本和类型，条目数为gauge，其余为counter
+func (p dnsCachePage) metric(key string) (string, string, prometheus.ValueType) {
+	name := strings.ToLower(replaceNonAlphanumeric(key))
+	if strings.HasPrefix(key, "Entries") {
+		return prometheus.BuildFQName("squid", p.subsystem, name),
+			"Number of " + p.prefix + " " + strings.ToLower(key), prometheus.GaugeValue
+	}
+	return prometheus.BuildFQName("squid", p.subsystem, name+"_total"),
+		"Total number of " + p.prefix + " " + strings.ToLower(key), prometheus.CounterValue
+}
+
+// SquidDNSCacheCollector 导出 mgr:ipcache 或 mgr:fqdncache 的条目数、命中、否定命中、未命中、请求数和无效请求数
+type SquidDNSCacheCollector struct {
+	source  *SnapshotSource
+	page    dnsCachePage
+	mu      sync.Mutex
+	metrics map[string]*perProcessMetric
+}
+
+// GetSquidIPCache 返回 mgr:ipcache 的收集器
+func GetSquidIPCache(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidIPCacheCollector(source)}
+}
+
+// GetSquidFQDNCache 返回 mgr:fqdncache 的收集器
+func GetSquidFQDNCache(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidFQDNCacheCollector(source)}
+}
+
+// NewSquidIPCacheCollector 创建 mgr:ipcache 的收集器
+func NewSquidIPCacheCollector(source *SnapshotSource) *SquidDNSCacheCollector {
+	return newSquidDNSCacheCollector(source, ipCachePage)
+}
+
+// NewSquidFQDNCacheCollector 创建 mgr:fqdncache 的收集器
+func NewSquidFQDNCacheCollector(source *SnapshotSource) *SquidDNSCacheCollector {
+	return newSquidDNSCacheCollector(source, fqdnCachePage)
+}
+
+func newSquidDNSCacheCollector(source *SnapshotSource, page dnsCachePage) *SquidDNSCacheCollector {
+	return &SquidDNSCacheCollector{
+		source:  source,
+		page:    page,
+		metrics: map[string]*perProcessMetric{},
+	}
+}
+
+// metric 返回统计项对应的指标，首次出现时创建
+func (c *SquidDNSCacheCollector) metric(key string) *perProcessMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m, ok := c.metrics[key]; ok {
+		return m
+	}
+	name, help, valueType := c.page.metric(key)
+	m := newPerProcessMetric(name, help, valueType)
+	c.metrics[key] = m
+	return m
+}
+
+// Describe 实现了Collector接口，统计项随Squid版本变化，不预先描述
+func (c *SquidDNSCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect实现了Collector接口，用于采集指标，SMP模式下同时输出各worker进程的值
+func (c *SquidDNSCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	values := newPageValues(c.page.page, c.page.parseDNSCacheStats)
+	stats := values.get(snapshot)
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		name, _, _ := c.page.metric(key)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		c.metric(key).collect(ch, snapshot, values.lookup(key))
+	}
+}
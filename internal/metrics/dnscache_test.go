@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析 IP 缓存和 FQDN 缓存统计项，缓存内容表格被忽略
+func TestParseDNSCacheStats(t *testing.T) {
+	ipcache := readPage(t, "ipcache")
+	fqdncache := readPage(t, "fqdncache")
+	ipcacheStats := map[string]float64{
+		"Entries In Use":      130,
+		"Entries Cached":      123,
+		"Requests":            4567,
+		"Hits":                4000,
+		"Negative Hits":       12,
+		"Numeric Hits":        100,
+		"Misses":              455,
+		"Retrieved A":         431,
+		"Retrieved AAAA":      398,
+		"Retrieved CNAME":     57,
+		"CNAME-Only Response": 0,
+		"Invalid Request":     0,
+	}
+
+	tests := []struct {
+		name     string
+		page     dnsCachePage
+		lines    []string
+		expected map[string]float64
+	}{
+		{
+			name:     "ipcache的统计项",
+			page:     ipCachePage,
+			lines:    ipcache,
+			expected: ipcacheStats,
+		},
+		{
+			name:  "fqdncache没有数字地址和解析记录的统计项",
+			page:  fqdnCachePage,
+			lines: fqdncache,
+			expected: map[string]float64{
+				"Entries In Use": 10,
+				"Entries Cached": 9,
+				"Requests":       100,
+				"Hits":           50,
+				"Negative Hits":  5,
+				"Misses":         45,
+			},
+		},
+		{
+			name:     "ipcache中的多地址和否定缓存条目",
+			page:     ipCachePage,
+			lines:    ipcache[17:],
+			expected: map[string]float64{},
+		},
+		{
+			name:     "fqdncache中的多主机名和否定缓存条目",
+			page:     fqdnCachePage,
+			lines:    fqdncache[8:],
+			expected: map[string]float64{},
+		},
+		{
+			name:     "另一个缓存的统计项",
+			page:     ipCachePage,
+			lines:    fqdncache,
+			expected: map[string]float64{},
+		},
+		{
+			name: "SMP模式下累加各进程的统计项",
+			page: ipCachePage,
+			lines: []string{
+				"by kid1 {\n", ipcache[0], ipcache[1], ipcache[5], ipcache[15], ipcache[17], ipcache[21], "} by kid1\n", "\n",
+				"by kid2 {\n", ipcache[0], "IPcache Entries In Use:  20\n", "IPcache Negative Hits:       3\n", ipcache[15], "} by kid2\n", "\n",
+			},
+			expected: map[string]float64{
+				"Entries In Use": 150,
+				"Negative Hits":  15,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.page.parseDNSCacheStats(tt.lines))
+		})
+	}
+}
+
+// 测试统计项对应的指标名和类型
+func TestDNSCacheMetric(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      dnsCachePage
+		key       string
+		expected  string
+		valueType prometheus.ValueType
+	}{
+		{name: "计数器", page: ipCachePage, key: "Negative Hits", expected: "squid_ipcache_negative_hits_total", valueType: prometheus.CounterValue},
+		{name: "带连字符的统计项", page: ipCachePage, key: "CNAME-Only Response", expected: "squid_ipcache_cname_only_response_total", valueType: prometheus.CounterValue},
+		{name: "条目数为gauge", page: fqdnCachePage, key: "Entries Cached", expected: "squid_fqdncache_entries_cached", valueType: prometheus.GaugeValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, _, valueType := tt.page.metric(tt.key)
+			assert.Equal(t, tt.expected, name)
+			assert.Equal(t, tt.valueType, valueType)
+		})
+	}
+}
+
+// 测试 FQDN 缓存收集器的输出
+func TestSquidFQDNCacheCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{fqdnCachePage.page: readPage(t, "fqdncache")}))
+
+	expected := `
+# HELP squid_fqdncache_entries_in_use Number of FQDNcache entries in use
+# TYPE squid_fqdncache_entries_in_use gauge
+squid_fqdncache_entries_in_use 10
+# HELP squid_fqdncache_negative_hits_total Total number of FQDNcache negative hits
+# TYPE squid_fqdncache_negative_hits_total counter
+squid_fqdncache_negative_hits_total 5
+`
+	collector := NewSquidFQDNCacheCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_fqdncache_entries_in_use", "squid_fqdncache_negative_hits_total"))
+	assert.Equal(t, 6, testutil.CollectAndCount(collector))
+
+	// 页面不可用时不输出
+	assert.Zero(t, testutil.CollectAndCount(NewSquidIPCacheCollector(source)))
+}
+
+// 测试SMP模式下 IP 缓存收集器输出聚合值和各进程的值
+func TestSquidIPCacheCollectorPerProcess(t *testing.T) {
+	host, port := newMgrPagesServer(t, map[string]string{
+		"/squid-internal-mgr/kid1/counters": "client_http.requests = 20\n",
+		"/squid-internal-mgr/kid2/counters": "client_http.requests = 10\n",
+		"/squid-internal-mgr/ipcache": "by kid1 {\nIPcache Negative Hits: 12\n} by kid1\n\n" +
+			"by kid2 {\nIPcache Negative Hits: 3\n} by kid2\n\n",
+		"/squid-internal-mgr/kid1/ipcache": "IPcache Negative Hits: 12\n",
+		"/squid-internal-mgr/kid2/ipcache": "IPcache Negative Hits: 3\n",
+	})
+	client := NewCacheObjectClient(&CacheObjectRequest{Hostname: host, Port: port, Transport: TransportHTTP})
+	source := NewSnapshotSource(client)
+	source.BeginScrape(context.Background())
+	defer source.EndScrape()
+
+	expected := `
+# HELP squid_ipcache_negative_hits_total Total number of IPcache negative hits
+# TYPE squid_ipcache_negative_hits_total counter
+squid_ipcache_negative_hits_total{process="all"} 15
+squid_ipcache_negative_hits_total{process="kid1"} 12
+squid_ipcache_negative_hits_total{process="kid2"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewSquidIPCacheCollector(source), strings.NewReader(expected)))
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// eventsPage 是事件队列所在的管理页面
+const eventsPage = "events"
+
+// queuedEvent 是 mgr:events 中的一个排队事件
+type queuedEvent struct {
+	Handler string
+	// Next 为距离下次执行的秒数
+	Next float64
+}
+
+// parseEvents 解析 mgr:events 的事件表，行以制表符分隔，形如
+// "MaintainSwapSpace        \t0.980 sec\t    1\t N/A"，表头和 "Last event to run:" 行被忽略
+func parseEvents(lines []string) []queuedEvent {
+	var events []queuedEvent
+	for _, line := range lines {
+		fields := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		next := strings.Fields(fields[1])
+		if len(next) != 2 || next[1] != "sec" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(next[0], 64)
+		if err != nil {
+			continue
+		}
+		handler := strings.TrimSpace(fields[0])
+		if handler == "" {
+			continue
+		}
+		events = append(events, queuedEvent{Handler: handler, Next: seconds})
+	}
+	return events
+}
+
+// GetSquidEvents 返回 mgr:events 的收集器
+func GetSquidEvents(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidEventsCollector(source)}
+}
+
+// SquidEventsCollector 按处理函数导出排队的事件数和最近一次执行前的秒数
+type SquidEventsCollector struct {
+	source *SnapshotSource
+	queued *prometheus.Desc
+	next   *prometheus.Desc
+}
+
+// NewSquidEventsCollector 创建新的事件队列收集器
+func NewSquidEventsCollector(source *SnapshotSource) *SquidEventsCollector {
+	return &SquidEventsCollector{
+		source: source,
+		queued: prometheus.NewDesc("squid_events_queued",
+			"Number of queued events by handler name", []string{"handler"}, nil),
+		next: prometheus.NewDesc("squid_events_next_run_seconds",
+			"Seconds until the next queued event of the handler runs", []string{"handler"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidEventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queued
+	ch <- c.next
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidEventsCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(eventsPage)
+	if err != nil {
+		return
+	}
+
+	queued := map[string]float64{}
+	next := map[string]float64{}
+	for _, event := range parseEvents(lines) {
+		if n, ok := next[event.Handler]; !ok || event.Next < n {
+			next[event.Handler] = event.Next
+		}
+		queued[event.Handler]++
+	}
+
+	handlers := make([]string, 0, len(queued))
+	for handler := range queued {
+		handlers = append(handlers, handler)
+	}
+	sort.Strings(handlers)
+	for _, handler := range handlers {
+		ch <- prometheus.MustNewConstMetric(c.queued, prometheus.GaugeValue, queued[handler], handler)
+		ch <- prometheus.MustNewConstMetric(c.next, prometheus.GaugeValue, next[handler], handler)
+	}
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析事件队列
+func TestParseEvents(t *testing.T) {
+	page := readPage(t, "events")
+	queued := []queuedEvent{
+		{Handler: "ipcache_purgelru", Next: 5.612},
+		{Handler: "fqdncache_purgelru", Next: 8.001},
+		{Handler: "storeDirClean", Next: 0.214},
+		{Handler: "MaintainSwapSpace", Next: 0.98},
+		{Handler: "peerDigestCheck", Next: 120.433},
+		{Handler: "memPoolCleanIdlePools", Next: 14.021},
+		{Handler: "peerDigestCheck", Next: 3540.118},
+		{Handler: "idnsCheckQueue", Next: 1},
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []queuedEvent
+	}{
+		{
+			name:     "同一处理函数的多个事件",
+			lines:    page,
+			expected: queued,
+		},
+		{
+			name:     "Last event to run和表头被忽略",
+			lines:    page[:3],
+			expected: nil,
+		},
+		{
+			name: "回调已失效的事件",
+			lines: []string{
+				page[2],
+				"peerDigestCheck          \t60.000 sec\t    0\t no\n",
+			},
+			expected: []queuedEvent{{Handler: "peerDigestCheck", Next: 60}},
+		},
+		{
+			name: "SMP模式下各进程的事件",
+			lines: []string{
+				"by kid1 {\n", page[0], page[1], page[2], page[5], "} by kid1\n", "\n",
+				"by kid2 {\n", page[0], page[1], page[2], page[5], page[10], "} by kid2\n", "\n",
+			},
+			expected: []queuedEvent{
+				{Handler: "storeDirClean", Next: 0.214},
+				{Handler: "storeDirClean", Next: 0.214},
+				{Handler: "idnsCheckQueue", Next: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseEvents(tt.lines))
+		})
+	}
+}
+
+// 测试事件队列收集器按处理函数汇总
+func TestSquidEventsCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{eventsPage: readPage(t, "events")}))
+
+	expected := `
+# HELP squid_events_next_run_seconds Seconds until the next queued event of the handler runs
+# TYPE squid_events_next_run_seconds gauge
+squid_events_next_run_seconds{handler="MaintainSwapSpace"} 0.98
+squid_events_next_run_seconds{handler="fqdncache_purgelru"} 8.001
+squid_events_next_run_seconds{handler="idnsCheckQueue"} 1
+squid_events_next_run_seconds{handler="ipcache_purgelru"} 5.612
+squid_events_next_run_seconds{handler="memPoolCleanIdlePools"} 14.021
+squid_events_next_run_seconds{handler="peerDigestCheck"} 120.433
+squid_events_next_run_seconds{handler="storeDirClean"} 0.214
+# HELP squid_events_queued Number of queued events by handler name
+# TYPE squid_events_queued gauge
+squid_events_queued{handler="MaintainSwapSpace"} 1
+squid_events_queued{handler="fqdncache_purgelru"} 1
+squid_events_queued{handler="idnsCheckQueue"} 1
+squid_events_queued{handler="ipcache_purgelru"} 1
+squid_events_queued{handler="memPoolCleanIdlePools"} 1
+squid_events_queued{handler="peerDigestCheck"} 2
+squid_events_queued{handler="storeDirClean"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewSquidEventsCollector(source), strings.NewReader(expected)))
+}
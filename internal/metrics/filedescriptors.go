@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fdPage 是文件描述符表所在的管理页面
+const fdPage = "filedescriptors"
+
+// fdTypes 是始终输出的文件描述符类型，没有时值为0
+var fdTypes = []string{"Socket", "File", "Pipe", "Log"}
+
+// fdPortClasses 把套接字的远端端口归类，未列出的端口按是否小于1024归为 well_known 或 high
+var fdPortClasses = map[int]string{
+	21:   "ftp",
+	53:   "dns",
+	80:   "http",
+	443:  "https",
+	8080: "http",
+	8443: "https",
+}
+
+// fdStates 按描述的前缀归类文件描述符的状态，空闲的客户端和服务器连接分别形如
+// "Idle client: Waiting for next request" 和 "Idle server: 93.184.216.34:80/example.com"
+var fdStates = []struct {
+	prefix string
+	state  string
+}{
+	{"Reading next request", "reading_request"},
+	{"Waiting for next request", "waiting_request"},
+	{"Idle client", "waiting_request"},
+	{"Idle server", "idle_server"},
+	{"HTTP Socket", "listening"},
+	{"HTTPS Socket", "listening"},
+	{"ICP Socket", "listening"},
+	{"HTCP Socket", "listening"},
+	{"SNMP Socket", "listening"},
+	{"SNMP Port", "listening"},
+	{"DNS Socket", "dns"},
+	{"ipc", "ipc"},
+}
+
+// fdEntry 是 mgr:filedescriptors 中的一行
+type fdEntry struct {
+	Type        string
+	Remote      string
+	Description string
+}
+
+// parseFileDescriptors 解析 mgr:filedescriptors 的文件描述符表，
+// 行形如 "  12 Socket  86396    1234*    5678  10.0.0.5:54321        Reading next request"，
+// 只有套接字带地址，Nread 和 Nwrite 后可能跟有 *。SMP模式下各进程的表依次出现，
+// 其中的描述符全部计入
+func parseFileDescriptors(lines []string) []fdEntry {
+	var entries []fdEntry
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+
+		entry := fdEntry{Type: fields[1]}
+		// 跳过 Tout、Nread 和 Nwrite 三列
+		rest := fields[2:]
+		for column := 0; column < 3 && len(rest) > 0; column++ {
+			rest = rest[1:]
+			if len(rest) > 0 && rest[0] == "*" {
+				rest = rest[1:]
+			}
+		}
+		if entry.Type == "Socket" && len(rest) > 0 && fdRemotePort(rest[0]) > 0 {
+			entry.Remote = rest[0]
+			rest = rest[1:]
+		}
+		entry.Description = strings.Join(rest, " ")
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// fdRemotePort 返回地址中的端口，不是地址时返回0。Squid输出的IPv6远端地址
+// 不带方括号，例如 "2001:db8::1:80"，此时最后一个冒号之后为端口
+func fdRemotePort(address string) int {
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		idx := strings.LastIndex(address, ":")
+		if idx < 0 || net.ParseIP(address[:idx]) == nil {
+			return 0
+		}
+		portStr = address[idx+1:]
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
+// fdPortClass 返回远端端口的类别
+func fdPortClass(port int) string {
+	if class, ok := fdPortClasses[port]; ok {
+		return class
+	}
+	if port < 1024 {
+		return "well_known"
+	}
+	return "high"
+}
+
+// fdState 根据描述归类文件描述符的状态，描述中包含URL时为 transfer
+func fdState(description string) string {
+	for _, state := range fdStates {
+		if strings.HasPrefix(description, state.prefix) {
+			return state.state
+		}
+	}
+	if strings.Contains(description, "://") || strings.HasPrefix(description, "CONNECT ") {
+		return "transfer"
+	}
+	return "other"
+}
+
+// GetSquidFileDescriptors 返回 mgr:filedescriptors 的收集器
+func GetSquidFileDescriptors(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidFileDescriptorsCollector(source)}
+}
+
+// SquidFileDescriptorsCollector 把文件描述符表汇总为按类型、端口类别和状态的数量，不按单个fd输出
+type SquidFileDescriptorsCollector struct {
+	source  *SnapshotSource
+	byType  *prometheus.Desc
+	byPort  *prometheus.Desc
+	byState *prometheus.Desc
+}
+
+// NewSquidFileDescriptorsCollector 创建新的文件描述符收集器
+func NewSquidFileDescriptorsCollector(source *SnapshotSource) *SquidFileDescriptorsCollector {
+	return &SquidFileDescriptorsCollector{
+		source: source,
+		byType: prometheus.NewDesc("squid_filedescriptors_open",
+			"Number of open file descriptors by type", []string{"type"}, nil),
+		byPort: prometheus.NewDesc("squid_filedescriptors_sockets_open",
+			"Number of open sockets with a remote address by remote port class", []string{"port_class"}, nil),
+		byState: prometheus.NewDesc("squid_filedescriptors_state",
+			"Number of open file descriptors by state derived from the description", []string{"state"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidFileDescriptorsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.byType
+	ch <- c.byPort
+	ch <- c.byState
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidFileDescriptorsCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(fdPage)
+	if err != nil {
+		return
+	}
+
+	types := map[string]float64{}
+	for _, fdType := range fdTypes {
+		types[fdType] = 0
+	}
+	ports := map[string]float64{}
+	states := map[string]float64{}
+	for _, entry := range parseFileDescriptors(lines) {
+		types[entry.Type]++
+		state := fdState(entry.Description)
+		states[state]++
+		// 监听套接字和DNS套接字的地址列为本地地址，不计入远端端口类别
+		if state == "listening" || state == "dns" {
+			continue
+		}
+		if port := fdRemotePort(entry.Remote); port > 0 {
+			ports[fdPortClass(port)]++
+		}
+	}
+
+	emitCounts(ch, c.byType, types)
+	emitCounts(ch, c.byPort, ports)
+	emitCounts(ch, c.byState, states)
+}
+
+// emitCounts 按标签值排序输出计数
+func emitCounts(ch chan<- prometheus.Metric, desc *prometheus.Desc, counts map[string]float64) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, counts[key], key)
+	}
+}
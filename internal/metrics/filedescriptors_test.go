@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析文件描述符表
+func TestParseFileDescriptors(t *testing.T) {
+	page := readPage(t, "filedescriptors")
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []fdEntry
+	}{
+		{
+			name:  "日志、管道、文件和套接字",
+			lines: page,
+			expected: []fdEntry{
+				{Type: "Log", Description: "/var/log/squid/cache.log"},
+				{Type: "Pipe", Description: "squid -> unlinkd"},
+				{Type: "Pipe", Description: "unlinkd -> squid"},
+				{Type: "Socket", Remote: "[::]:40517", Description: "DNS Socket IPv6"},
+				{Type: "Socket", Remote: "0.0.0.0:38281", Description: "DNS Socket IPv4"},
+				{Type: "Log", Description: "/var/log/squid/access.log"},
+				{Type: "Socket", Remote: "[::]:3128", Description: "HTTP Socket"},
+				{Type: "File", Description: "/var/spool/squid/swap.state"},
+				{Type: "Socket", Remote: "10.0.0.5:51234", Description: "Idle client: Waiting for next request"},
+				{Type: "Socket", Remote: "93.184.216.34:80", Description: "http://example.com/index.html"},
+				{Type: "Socket", Remote: "93.184.216.34:80", Description: "Idle server: 93.184.216.34:80/example.com"},
+				{Type: "Socket", Remote: "2001:db8::5:51235", Description: "http://example.org/logo.png"},
+				{Type: "Socket", Remote: "10.0.0.6:40000", Description: "Reading next request"},
+				{Type: "File", Description: "/var/spool/squid/00/00/0000001A"},
+			},
+		},
+		{
+			name:     "表头和分隔线",
+			lines:    page[:3],
+			expected: nil,
+		},
+		{
+			name:  "Nread和Nwrite后的星号",
+			lines: []string{page[12], page[14]},
+			expected: []fdEntry{
+				{Type: "Socket", Remote: "93.184.216.34:80", Description: "http://example.com/index.html"},
+				{Type: "Socket", Remote: "2001:db8::5:51235", Description: "http://example.org/logo.png"},
+			},
+		},
+		{
+			name: "没有远端地址的套接字",
+			lines: []string{
+				"  21 Socket    0       0        0                        ICP Socket\n",
+			},
+			expected: []fdEntry{{Type: "Socket", Description: "ICP Socket"}},
+		},
+		{
+			name: "SMP模式下各进程的描述符",
+			lines: []string{
+				"by kid1 {\n", page[0], page[1], page[2], page[3], page[11], "} by kid1\n", "\n",
+				"by kid2 {\n", page[0], page[1], page[2], page[3], "} by kid2\n", "\n",
+			},
+			expected: []fdEntry{
+				{Type: "Log", Description: "/var/log/squid/cache.log"},
+				{Type: "Socket", Remote: "10.0.0.5:51234", Description: "Idle client: Waiting for next request"},
+				{Type: "Log", Description: "/var/log/squid/cache.log"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseFileDescriptors(tt.lines))
+		})
+	}
+}
+
+// 测试从地址列中取得端口
+func TestFDRemotePort(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		expected int
+	}{
+		{name: "IPv4", address: "10.0.0.5:54321", expected: 54321},
+		{name: "带方括号的IPv6", address: "[::]:3128", expected: 3128},
+		{name: "不带方括号的IPv6", address: "2001:db8::1:443", expected: 443},
+		{name: "文件路径", address: "/var/log/squid/cache.log", expected: 0},
+		{name: "URL", address: "http://example.com/", expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fdRemotePort(tt.address))
+		})
+	}
+}
+
+// 测试文件描述符收集器按类型、端口类别和状态汇总，
+// 监听套接字和DNS套接字的本地地址不计入远端端口类别
+func TestSquidFileDescriptorsCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{fdPage: readPage(t, "filedescriptors")}))
+
+	expected := `
+# HELP squid_filedescriptors_open Number of open file descriptors by type
+# TYPE squid_filedescriptors_open gauge
+squid_filedescriptors_open{type="File"} 2
+squid_filedescriptors_open{type="Log"} 2
+squid_filedescriptors_open{type="Pipe"} 2
+squid_filedescriptors_open{type="Socket"} 8
+# HELP squid_filedescriptors_sockets_open Number of open sockets with a remote address by remote port class
+# TYPE squid_filedescriptors_sockets_open gauge
+squid_filedescriptors_sockets_open{port_class="high"} 3
+squid_filedescriptors_sockets_open{port_class="http"} 2
+# HELP squid_filedescriptors_state Number of open file descriptors by state derived from the description
+# TYPE squid_filedescriptors_state gauge
+squid_filedescriptors_state{state="dns"} 2
+squid_filedescriptors_state{state="idle_server"} 1
+squid_filedescriptors_state{state="listening"} 1
+squid_filedescriptors_state{state="other"} 6
+squid_filedescriptors_state{state="reading_request"} 1
+squid_filedescriptors_state{state="transfer"} 2
+squid_filedescriptors_state{state="waiting_request"} 1
+`
+	collector := NewSquidFileDescriptorsCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// idnsPage 是内部DNS解析器统计信息所在的管理页面
+const idnsPage = "idns"
+
+// idnsRcodeNames 把DNS响应码映射为名称，未列出的响应码使用数字
+var idnsRcodeNames = map[string]string{
+	"0": "NOERROR",
+	"1": "FORMERR",
+	"2": "SERVFAIL",
+	"3": "NXDOMAIN",
+	"4": "NOTIMP",
+	"5": "REFUSED",
+}
+
+// idnsNameserver 是 Nameservers 表格中的一行
+type idnsNameserver struct {
+	Address string
+	Queries float64
+	Replies float64
+}
+
+// idnsRcode 是 Rcode Matrix 表格中一个响应码在某次尝试中的数量
+type idnsRcode struct {
+	Rcode   string
+	Attempt string
+	Count   float64
+}
+
+// idnsStats 是 mgr:idns 的解析结果
+type idnsStats struct {
+	Pending     float64
+	Nameservers []idnsNameserver
+	Rcodes      []idnsRcode
+}
+
+// parseIDNS 按段落解析 mgr:idns 页面，段落标题为 "The Queue:"、"Nameservers:" 和 "Rcode Matrix:"
+func parseIDNS(lines []string) idnsStats {
+	var stats idnsStats
+	var section string
+	var attempts []string
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ":") {
+			section = trimmed
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		switch section {
+		case "The Queue:":
+			// 队列中的每个查询以 %#06x 格式的ID开头，ID为0时没有 0x 前缀
+			if _, err := strconv.ParseUint(strings.TrimPrefix(fields[0], "0x"), 16, 16); err == nil {
+				stats.Pending++
+			}
+		case "Nameservers:":
+			if len(fields) < 3 {
+				continue
+			}
+			queries, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				continue
+			}
+			replies, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				continue
+			}
+			stats.Nameservers = append(stats.Nameservers, idnsNameserver{Address: fields[0], Queries: queries, Replies: replies})
+		case "Rcode Matrix:":
+			// 表头形如 "RCODE ATTEMPT1 ATTEMPT2 ATTEMPT3 PROBLEM"，每行末尾为 ": Success" 等说明
+			if fields[0] == "RCODE" {
+				attempts = attempts[:0]
+				for _, field := range fields[1:] {
+					if attempt, ok := strings.CutPrefix(field, "ATTEMPT"); ok {
+						attempts = append(attempts, attempt)
+					}
+				}
+				continue
+			}
+			if _, err := strconv.Atoi(fields[0]); err != nil {
+				continue
+			}
+			for i, field := range fields[1:] {
+				if i >= len(attempts) {
+					break
+				}
+				if count, err := strconv.ParseFloat(field, 64); err == nil {
+					stats.Rcodes = append(stats.Rcodes, idnsRcode{Rcode: fields[0], Attempt: attempts[i], Count: count})
+				}
+			}
+		}
+	}
+	return stats
+}
+
+// GetSquidIDNS 返回 mgr:idns 的收集器
+func GetSquidIDNS(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidIDNSCollector(source)}
+}
+
+// SquidIDNSCollector 导出内部DNS解析器每个上游服务器的查询和应答数、响应码分布和等待中的查询数
+type SquidIDNSCollector struct {
+	source  *SnapshotSource
+	queries *prometheus.Desc
+	replies *prometheus.Desc
+	rcodes  *prometheus.Desc
+	pending *prometheus.Desc
+}
+
+// NewSquidIDNSCollector 创建新的内部DNS解析器收集器
+func NewSquidIDNSCollector(source *SnapshotSource) *SquidIDNSCollector {
+	return &SquidIDNSCollector{
+		source: source,
+		queries: prometheus.NewDesc("squid_idns_queries_total",
+			"Total number of DNS queries sent to the nameserver", []string{"nameserver"}, nil),
+		replies: prometheus.NewDesc("squid_idns_replies_total",
+			"Total number of DNS replies received from the nameserver", []string{"nameserver"}, nil),
+		rcodes: prometheus.NewDesc("squid_idns_rcode_total",
+			"Total number of DNS replies by response code and attempt", []string{"rcode", "attempt"}, nil),
+		pending: prometheus.NewDesc("squid_idns_pending_queries",
+			"Number of DNS queries waiting for a reply", nil, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidIDNSCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queries
+	ch <- c.replies
+	ch <- c.rcodes
+	ch <- c.pending
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidIDNSCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(idnsPage)
+	if err != nil {
+		return
+	}
+	stats := parseIDNS(lines)
+
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, stats.Pending)
+
+	// 同一服务器出现多次时（例如不同类型或SMP模式下不同进程）合并
+	queries := map[string]float64{}
+	replies := map[string]float64{}
+	for _, nameserver := range stats.Nameservers {
+		queries[nameserver.Address] += nameserver.Queries
+		replies[nameserver.Address] += nameserver.Replies
+	}
+	addresses := make([]string, 0, len(queries))
+	for address := range queries {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		ch <- prometheus.MustNewConstMetric(c.queries, prometheus.CounterValue, queries[address], address)
+		ch <- prometheus.MustNewConstMetric(c.replies, prometheus.CounterValue, replies[address], address)
+	}
+
+	// SMP模式下每个进程各有一个响应码表格，累加后输出
+	var keys []idnsRcode
+	counts := map[idnsRcode]float64{}
+	for _, rcode := range stats.Rcodes {
+		name := rcode.Rcode
+		if rcodeName, ok := idnsRcodeNames[name]; ok {
+			name = rcodeName
+		}
+		key := idnsRcode{Rcode: name, Attempt: rcode.Attempt}
+		if _, ok := counts[key]; !ok {
+			keys = append(keys, key)
+		}
+		counts[key] += rcode.Count
+	}
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(c.rcodes, prometheus.CounterValue, counts[key], key.Rcode, key.Attempt)
+	}
+}
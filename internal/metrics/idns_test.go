@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析内部DNS解析器统计信息
+func TestParseIDNS(t *testing.T) {
+	page := readPage(t, "idns")
+	kid := func(n string, lines ...string) []string {
+		return append(append([]string{"by kid" + n + " {\n"}, lines...), "} by kid"+n+"\n", "\n")
+	}
+
+	tests := []struct {
+		name        string
+		lines       []string
+		pending     float64
+		nameservers []idnsNameserver
+		rcodes      int
+		nonZero     []idnsRcode
+	}{
+		{
+			name:    "队列、上游服务器和响应码",
+			lines:   page,
+			pending: 2,
+			nameservers: []idnsNameserver{
+				{Address: "192.168.1.1", Queries: 1234, Replies: 1200},
+				{Address: "2001:db8::53", Queries: 10, Replies: 0},
+			},
+			rcodes: 36,
+			nonZero: []idnsRcode{
+				{Rcode: "0", Attempt: "1", Count: 1100},
+				{Rcode: "0", Attempt: "2", Count: 5},
+				{Rcode: "2", Attempt: "1", Count: 20},
+				{Rcode: "2", Attempt: "2", Count: 1},
+				{Rcode: "3", Attempt: "1", Count: 75},
+			},
+		},
+		{
+			name: "ID为0和带M标记的查询",
+			lines: []string{
+				page[2], page[3], page[4], page[5],
+				"000000   44     1      0.500     0.500   example.net\n",
+				"0x1a2c   44     1      0.012     0.012 M example.com\n",
+				page[8], page[9],
+			},
+			pending: 2,
+		},
+		{
+			name: "没有Type列的旧格式",
+			lines: []string{
+				page[11],
+				"IP ADDRESS                                     # QUERIES # REPLIES\n",
+				"---------------------------------------------- --------- ---------\n",
+				"2001:db8::53                                         10         7\n",
+			},
+			nameservers: []idnsNameserver{{Address: "2001:db8::53", Queries: 10, Replies: 7}},
+		},
+		{
+			name: "尝试次数由表头决定",
+			lines: []string{
+				page[17],
+				"RCODE ATTEMPT1 ATTEMPT2 PROBLEM\n",
+				"    3       75        2 : Non-Existent Domain\n",
+				"   16        1        0 : Bad OPT Version or TSIG Signature Failure\n",
+			},
+			rcodes: 4,
+			nonZero: []idnsRcode{
+				{Rcode: "3", Attempt: "1", Count: 75},
+				{Rcode: "3", Attempt: "2", Count: 2},
+				{Rcode: "16", Attempt: "1", Count: 1},
+			},
+		},
+		{
+			name:    "SMP模式下各进程的表格",
+			lines:   append(kid("1", page...), kid("2", page[0], page[2], page[6], page[11], page[14], page[17], page[18], page[19])...),
+			pending: 3,
+			nameservers: []idnsNameserver{
+				{Address: "192.168.1.1", Queries: 1234, Replies: 1200},
+				{Address: "2001:db8::53", Queries: 10, Replies: 0},
+				{Address: "192.168.1.1", Queries: 1234, Replies: 1200},
+			},
+			rcodes: 39,
+			nonZero: []idnsRcode{
+				{Rcode: "0", Attempt: "1", Count: 1100},
+				{Rcode: "0", Attempt: "2", Count: 5},
+				{Rcode: "2", Attempt: "1", Count: 20},
+				{Rcode: "2", Attempt: "2", Count: 1},
+				{Rcode: "3", Attempt: "1", Count: 75},
+				{Rcode: "0", Attempt: "1", Count: 1100},
+				{Rcode: "0", Attempt: "2", Count: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := parseIDNS(tt.lines)
+			assert.Equal(t, tt.pending, stats.Pending)
+			assert.Equal(t, tt.nameservers, stats.Nameservers)
+			assert.Len(t, stats.Rcodes, tt.rcodes)
+
+			var nonZero []idnsRcode
+			for _, rcode := range stats.Rcodes {
+				if rcode.Count > 0 {
+					nonZero = append(nonZero, rcode)
+				}
+			}
+			assert.Equal(t, tt.nonZero, nonZero)
+		})
+	}
+}
+
+// 测试内部DNS解析器收集器的输出
+func TestSquidIDNSCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{idnsPage: readPage(t, "idns")}))
+
+	expected := `
+# HELP squid_idns_pending_queries Number of DNS queries waiting for a reply
+# TYPE squid_idns_pending_queries gauge
+squid_idns_pending_queries 2
+# HELP squid_idns_replies_total Total number of DNS replies received from the nameserver
+# TYPE squid_idns_replies_total counter
+squid_idns_replies_total{nameserver="192.168.1.1"} 1200
+squid_idns_replies_total{nameserver="2001:db8::53"} 0
+`
+	collector := NewSquidIDNSCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_idns_pending_queries", "squid_idns_replies_total"))
+	assert.Equal(t, 36, testutil.CollectAndCount(collector, "squid_idns_rcode_total"), "十二个响应码各三次尝试")
+}
+
+// 测试SMP模式下收集器累加各进程的上游服务器和响应码
+func TestSquidIDNSCollectorSMP(t *testing.T) {
+	page := readPage(t, "idns")
+	var lines []string
+	for _, kid := range []string{"1", "2"} {
+		lines = append(lines, "by kid"+kid+" {\n", page[2], page[6], page[11], page[14], page[17], page[18], page[19])
+		lines = append(lines, "} by kid"+kid+"\n", "\n")
+	}
+	source := NewSnapshotSource(newPageClient(map[string][]string{idnsPage: lines}))
+
+	expected := `
+# HELP squid_idns_pending_queries Number of DNS queries waiting for a reply
+# TYPE squid_idns_pending_queries gauge
+squid_idns_pending_queries 2
+# HELP squid_idns_queries_total Total number of DNS queries sent to the nameserver
+# TYPE squid_idns_queries_total counter
+squid_idns_queries_total{nameserver="192.168.1.1"} 2468
+# HELP squid_idns_rcode_total Total number of DNS replies by response code and attempt
+# TYPE squid_idns_rcode_total counter
+squid_idns_rcode_total{attempt="1",rcode="NOERROR"} 2200
+squid_idns_rcode_total{attempt="2",rcode="NOERROR"} 10
+squid_idns_rcode_total{attempt="3",rcode="NOERROR"} 0
+`
+	collector := NewSquidIDNSCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_idns_pending_queries", "squid_idns_queries_total", "squid_idns_rcode_total"))
+}
@@ -3,8 +3,10 @@
 package metrics
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
 	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // 定义Squid信息指标类型
@@ -14,7 +16,7 @@ type squidInfos struct {
 	Unit        string
 }
 
-// Squid信息指标列表
+// Squid信息指标列表，列表中的键保留原有的指标名和帮助文本
 var squidInfosList = []squidInfos{
 	{"Number_of_clients_accessing_cache", "", "number"},
 	{"Number_of_HTTP_requests_received", "", "number"},
@@ -64,71 +66,170 @@ var squidInfosList = []squidInfos{
 	{"on_disk_objects", "", "number"},
 }
 
-// GetSquidInfos 返回所有Squid信息指标
-func GetSquidInfos() []prometheus.Collector {
-	collectors := []prometheus.Collector{}
+// squidInfoCatalogue 按键索引的信息指标列表
+var squidInfoCatalogue = func() map[string]squidInfos {
+	catalogue := make(map[string]squidInfos, len(squidInfosList))
 	for _, info := range squidInfosList {
-		collectors = append(collectors,
-			NewSquidInfo(info.Section, info.Description, info.Unit))
+		catalogue[info.Section] = info
 	}
-	return collectors
+	return catalogue
+}()
+
+// squidInfoTimestamps 是以unix时间戳导出的时间信息
+var squidInfoTimestamps = map[string]squidInfoMetric{
+	"Start_Time":   {Name: "squid_start_time_seconds", Description: "Time squid was started, in unix seconds"},
+	"Current_Time": {Name: "squid_current_time_seconds", Description: "Current time reported by squid, in unix seconds"},
 }
 
-// SquidInfo 是用于存储Squid信息的指标
-type SquidInfo struct {
-	*baseMetrics
-	section string
+// squidInfoLabels 把 squid_info 项中的字符串信息映射为 squid_info 指标的标签
+var squidInfoLabels = []struct {
+	Key   string
+	Label string
+}{
+	{"Squid_Object_Cache_Version", "version"},
+	{"Build_Info", "build"},
+	{"Service_Name", "service"},
 }
 
-// NewSquidInfo创建一个新的SquidInfo实例
-func NewSquidInfo(section, description, unit string) *SquidInfo {
-	var name string
-	var help string
+// squidInfoMetric 描述一个信息键导出的指标
+type squidInfoMetric struct {
+	Name        string
+	Description string
+	// Window 为中位数服务时间的统计窗口，为空时指标不带 window 标签
+	Window string
+}
 
-	name = prometheus.BuildFQName("squid", "info", strings.Replace(section, "%", "pct", -1))
+// infoForKey 返回信息键对应的指标，列表之外的键按键名生成指标名，
+// 中位数服务时间按类别导出为带 window 标签的指标族
+func infoForKey(key string) squidInfoMetric {
+	if info, ok := squidInfoCatalogue[key]; ok {
+		help := info.Description
+		if help == "" {
+			help = strings.Replace(info.Section, "_", " ", -1)
+		}
+		return squidInfoMetric{
+			Name:        prometheus.BuildFQName("squid", "info", strings.Replace(info.Section, "%", "pct", -1)),
+			Description: help + " in " + info.Unit,
+		}
+	}
+	if info, ok := squidInfoTimestamps[key]; ok {
+		return info
+	}
 
-	if description == "" {
-		help = strings.Replace(section, "_", " ", -1)
-	} else {
-		help = description
+	if strings.HasPrefix(key, medianInfoPrefix) {
+		for suffix, window := range map[string]string{"_5min": serviceTimeWindow5m, "_60min": serviceTimeWindow60m} {
+			if !strings.HasSuffix(key, suffix) {
+				continue
+			}
+			category := strings.TrimSuffix(strings.TrimPrefix(key, medianInfoPrefix), suffix)
+			return squidInfoMetric{
+				Name: prometheus.BuildFQName("squid", "info",
+					"median_"+strings.ToLower(replaceNonAlphanumeric(category))+"_seconds"),
+				Description: "Median service time of " + strings.Replace(category, "_", " ", -1) + " in seconds",
+				Window:      window,
+			}
+		}
+	}
+
+	return squidInfoMetric{
+		Name:        prometheus.BuildFQName("squid", "info", replaceNonAlphanumeric(strings.Replace(key, "%", "pct", -1))),
+		Description: strings.Replace(key, "_", " ", -1),
+	}
+}
+
+// GetSquidInfos 返回Squid信息指标收集器，mgr:info 中的每个数值项都会被导出
+func GetSquidInfos(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidInfosCollector(source)}
+}
+
+// SquidInfosCollector 动态导出 mgr:info 中的全部数值项和 squid_info 指标
+type SquidInfosCollector struct {
+	source   *SnapshotSource
+	infoDesc *prometheus.Desc
+	mu       sync.Mutex
+	metrics  map[string]*perProcessMetric
+}
+
+// NewSquidInfosCollector 创建新的信息收集器
+func NewSquidInfosCollector(source *SnapshotSource) *SquidInfosCollector {
+	labels := make([]string, 0, len(squidInfoLabels))
+	for _, label := range squidInfoLabels {
+		labels = append(labels, label.Label)
+	}
+	return &SquidInfosCollector{
+		source: source,
+		infoDesc: prometheus.NewDesc("squid_info",
+			"Information about the squid instance, the value is always 1", labels, nil),
+		metrics: map[string]*perProcessMetric{},
 	}
+}
 
-	help = help + " in " + unit
+// metric 返回指标名对应的指标，首次出现时创建，同一指标族的不同窗口共用一个指标
+func (c *SquidInfosCollector) metric(info squidInfoMetric) *perProcessMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return &SquidInfo{
-		baseMetrics: NewMetrics(name, help, []string{}),
-		section:     section,
+	if m, ok := c.metrics[info.Name]; ok {
+		return m
+	}
+	var m *perProcessMetric
+	if info.Window != "" {
+		m = newPerProcessMetric(info.Name, info.Description, prometheus.GaugeValue, "window")
+	} else {
+		m = newPerProcessMetric(info.Name, info.Description, prometheus.GaugeValue)
 	}
+	c.metrics[info.Name] = m
+	return m
 }
 
-// Describe 实现了Collector接口
-func (si *SquidInfo) Describe(ch chan<- *prometheus.Desc) {
-	ch <- si.baseMetrics.desc
+// Describe 实现了Collector接口，指标随Squid的输出动态变化，不预先描述
+func (c *SquidInfosCollector) Describe(ch chan<- *prometheus.Desc) {
 }
 
 // Collect实现了Collector接口，用于采集指标
-func (si *SquidInfo) Collect(ch chan<- prometheus.Metric) {
-	// 创建一个客户端连接Squid服务器
-	client := NewCacheObjectClient(&CacheObjectRequest{
-		Hostname: GlobalHostname,
-		Port:     GlobalPort,
-		Login:    GlobalLogin,
-		Password: GlobalPassword,
-		Headers:  GlobalHeaders,
-	})
-
-	infos, err := client.GetInfos()
+func (c *SquidInfosCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	infos, err := snapshot.Infos()
 	if err != nil {
-		// 连接失败，记录错误并返回
 		return
 	}
 
-	// 查找匹配的指标
+	// 不同的键清理后可能得到相同的指标名，只输出第一个
+	seen := make(map[string]bool, len(infos))
 	for _, info := range infos {
-		if info.Key == si.section {
-			// 找到匹配的指标，使用实际数据
-			ch <- prometheus.MustNewConstMetric(si.baseMetrics.desc, prometheus.GaugeValue, info.Value)
-			return
+		if info.Key == infoLabelsKey {
+			c.collectInfo(ch, info)
+			continue
+		}
+
+		key := info.Key
+		metric := infoForKey(key)
+		if seen[metric.Name+"/"+metric.Window] {
+			continue
+		}
+		seen[metric.Name+"/"+metric.Window] = true
+
+		// SMP模式下同时输出各worker进程的值
+		lookup := func(snapshot *Snapshot) (float64, bool) {
+			return snapshot.Info(key)
+		}
+		if metric.Window != "" {
+			c.metric(metric).collect(ch, snapshot, lookup, metric.Window)
+		} else {
+			c.metric(metric).collect(ch, snapshot, lookup)
+		}
+	}
+}
+
+// collectInfo 输出带版本、编译信息和服务名标签的 squid_info 指标
+func (c *SquidInfosCollector) collectInfo(ch chan<- prometheus.Metric, info Counter) {
+	values := make([]string, len(squidInfoLabels))
+	for i, label := range squidInfoLabels {
+		for _, varLabel := range info.VarLabels {
+			if varLabel.Key == label.Key {
+				values[i] = varLabel.Value
+			}
 		}
 	}
+	ch <- prometheus.MustNewConstMetric(c.infoDesc, prometheus.GaugeValue, 1, values...)
 }
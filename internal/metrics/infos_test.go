@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -603,3 +604,97 @@ func TestLargeScaleInfoProcessing(t *testing.T) {
 	assert.Equal(t, "Info_199", infos[199].Key, "最后一个记录键应匹配")
 	assert.Equal(t, 1990.0, infos[199].Value, "最后一个记录值应匹配")
 }
+
+// 模拟的 mgr:info 页面
+var infoPage = []string{
+	"Squid Object Cache: Version 6.1\n",
+	"Build Info: Debian linux\n",
+	"Service Name: squid\n",
+	"Start Time:\tTue, 14 Nov 2023 22:13:20 GMT\n",
+	"Current Time:\tTue, 14 Nov 2023 23:13:20 GMT\n",
+	"Connection information for squid:\n",
+	"\tNumber of clients accessing cache:\t3\n",
+	"\tNumber of HTCP messages received:\t0\n",
+	"Cache information for squid:\n",
+	"\tHits as % of all requests:\t5min: 12.5%, 60min: 10.0%\n",
+	"Median Service Times (seconds)  5 min    60 min:\n",
+	"\tHTTP Requests (All):   0.00865  0.01035\n",
+	"\tDNS Lookups:           0.00094  0.00100\n",
+	"Resource usage for squid:\n",
+	"\tUP Time:\t3600.000 seconds\n",
+	"\tNew Resource:\t42\n",
+}
+
+// 使用 infoParser 解析页面的模拟客户端
+type infoPageClient struct {
+	lines []string
+}
+
+func (c *infoPageClient) GetCounters() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *infoPageClient) GetServiceTimes() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *infoPageClient) GetInfos() ([]Counter, error) {
+	parser := newInfoParser()
+	for _, line := range c.lines {
+		parser.parseLine(line)
+	}
+	return parser.result(), nil
+}
+
+// 测试按段落解析 mgr:info 页面
+func TestInfoParser(t *testing.T) {
+	infos, _ := (&infoPageClient{lines: infoPage}).GetInfos()
+
+	values := map[string]float64{}
+	for _, info := range infos {
+		values[info.Key] = info.Value
+	}
+	assert.Equal(t, 1700000000.0, values["Start_Time"], "启动时间应为unix时间戳")
+	assert.Equal(t, 1700003600.0, values["Current_Time"], "当前时间应为unix时间戳")
+	assert.Equal(t, 12.5, values["Hits_as_%_of_all_requests_5min"])
+	assert.Equal(t, 10.0, values["Hits_as_%_of_all_requests_60min"])
+	assert.Equal(t, 0.00865, values["Median_HTTP_Requests_All_5min"], "应保留中位数服务时间")
+	assert.Equal(t, 0.001, values["Median_DNS_Lookups_60min"])
+	assert.Equal(t, 42.0, values["New_Resource"], "列表之外的项也应保留")
+
+	last := infos[len(infos)-1]
+	assert.Equal(t, infoLabelsKey, last.Key)
+	assert.Equal(t, []VarLabel{
+		{Key: "Squid_Object_Cache_Version", Value: "6.1"},
+		{Key: "Build_Info", Value: "Debian linux"},
+		{Key: "Service_Name", Value: "squid"},
+	}, last.VarLabels, "字符串信息应作为标签保留")
+}
+
+// 测试信息收集器的输出
+func TestSquidInfosCollector(t *testing.T) {
+	source := NewSnapshotSource(&infoPageClient{lines: infoPage})
+
+	expected := `
+# HELP squid_info Information about the squid instance, the value is always 1
+# TYPE squid_info gauge
+squid_info{build="Debian linux",service="squid",version="6.1"} 1
+# HELP squid_info_median_http_requests_all_seconds Median service time of HTTP Requests All in seconds
+# TYPE squid_info_median_http_requests_all_seconds gauge
+squid_info_median_http_requests_all_seconds{window="5m"} 0.00865
+squid_info_median_http_requests_all_seconds{window="60m"} 0.01035
+# HELP squid_info_New_Resource New Resource
+# TYPE squid_info_New_Resource gauge
+squid_info_New_Resource 42
+# HELP squid_info_Number_of_clients_accessing_cache Number of clients accessing cache in number
+# TYPE squid_info_Number_of_clients_accessing_cache gauge
+squid_info_Number_of_clients_accessing_cache 3
+# HELP squid_start_time_seconds Time squid was started, in unix seconds
+# TYPE squid_start_time_seconds gauge
+squid_start_time_seconds 1.7e+09
+`
+	collector := NewSquidInfosCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_info", "squid_info_median_http_requests_all_seconds", "squid_info_New_Resource",
+		"squid_info_Number_of_clients_accessing_cache", "squid_start_time_seconds"))
+}
@@ -0,0 +1,225 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// memPage 是内存池统计信息所在的管理页面
+const memPage = "mem"
+
+// mgr:mem 内存池表格中各列的位置，表格以制表符分隔，chunk 相关的列可能为空
+const (
+	memColumnName          = 0
+	memColumnObjSize       = 1
+	memColumnAllocated     = 9
+	memColumnAllocatedKB   = 10
+	memColumnAllocatedHigh = 11
+	memColumnInUse         = 14
+	memColumnInUseKB       = 15
+	memColumnInUseHigh     = 16
+	memColumnIdle          = 19
+	memColumnIdleKB        = 20
+	memColumnIdleHigh      = 21
+	memColumns             = 22
+)
+
+// memPool 是 mgr:mem 中一个内存池的统计信息，大小均为字节
+type memPool struct {
+	Name           string
+	ObjectSize     float64
+	Allocated      float64
+	AllocatedBytes float64
+	AllocatedHigh  float64
+	InUse          float64
+	InUseBytes     float64
+	InUseHigh      float64
+	Idle           float64
+	IdleBytes      float64
+	IdleHigh       float64
+}
+
+// MemPoolOptions 限制导出的内存池数量，Allow 不为空时只导出其中的内存池，
+// 否则导出已分配字节数最多的 Top 个内存池，Top 不大于0时导出全部
+type MemPoolOptions struct {
+	Top   int
+	Allow []string
+}
+
+// add 累加另一个进程中同名内存池的统计信息
+func (p *memPool) add(other memPool) {
+	p.Allocated += other.Allocated
+	p.AllocatedBytes += other.AllocatedBytes
+	p.AllocatedHigh += other.AllocatedHigh
+	p.InUse += other.InUse
+	p.InUseBytes += other.InUseBytes
+	p.InUseHigh += other.InUseHigh
+	p.Idle += other.Idle
+	p.IdleBytes += other.IdleBytes
+	p.IdleHigh += other.IdleHigh
+}
+
+// parseMemPools 解析 mgr:mem 的内存池表格，SMP模式下累加各进程中同名的内存池
+func parseMemPools(lines []string) []memPool {
+	var pools []memPool
+	index := map[string]int{}
+	for _, section := range kidSections(lines) {
+		for _, pool := range parseMemSection(section) {
+			if i, ok := index[pool.Name]; ok {
+				pools[i].add(pool)
+				continue
+			}
+			index[pool.Name] = len(pools)
+			pools = append(pools, pool)
+		}
+	}
+	return pools
+}
+
+// parseMemSection 解析一个进程的内存池表格，忽略表头、汇总行和 String Pool 表格，
+// 同名的内存池只保留第一个
+func parseMemSection(lines []string) []memPool {
+	var pools []memPool
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "String Pool") {
+			break
+		}
+		fields := strings.Split(strings.TrimRight(line, "\r\n"), "\t")
+		if len(fields) < memColumns {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		name := fields[memColumnName]
+		if name == "" || name == "Pool" || name == "Total" || seen[name] {
+			continue
+		}
+
+		values := make([]float64, len(fields))
+		valid := true
+		for _, column := range []int{memColumnAllocated, memColumnAllocatedKB, memColumnInUse, memColumnInUseKB, memColumnIdle, memColumnIdleKB} {
+			value, err := strconv.ParseFloat(fields[column], 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[column] = value
+		}
+		if !valid {
+			continue
+		}
+		for _, column := range []int{memColumnObjSize, memColumnAllocatedHigh, memColumnInUseHigh, memColumnIdleHigh} {
+			values[column], _ = strconv.ParseFloat(fields[column], 64)
+		}
+
+		seen[name] = true
+		pools = append(pools, memPool{
+			Name:           name,
+			ObjectSize:     values[memColumnObjSize],
+			Allocated:      values[memColumnAllocated],
+			AllocatedBytes: values[memColumnAllocatedKB] * 1024,
+			AllocatedHigh:  values[memColumnAllocatedHigh] * 1024,
+			InUse:          values[memColumnInUse],
+			InUseBytes:     values[memColumnInUseKB] * 1024,
+			InUseHigh:      values[memColumnInUseHigh] * 1024,
+			Idle:           values[memColumnIdle],
+			IdleBytes:      values[memColumnIdleKB] * 1024,
+			IdleHigh:       values[memColumnIdleHigh] * 1024,
+		})
+	}
+	return pools
+}
+
+// selectMemPools 按配置选择要导出的内存池
+func selectMemPools(pools []memPool, opts MemPoolOptions) []memPool {
+	if len(opts.Allow) > 0 {
+		allowed := make(map[string]bool, len(opts.Allow))
+		for _, name := range opts.Allow {
+			allowed[name] = true
+		}
+		var selected []memPool
+		for _, pool := range pools {
+			if allowed[pool.Name] {
+				selected = append(selected, pool)
+			}
+		}
+		return selected
+	}
+
+	if opts.Top <= 0 || len(pools) <= opts.Top {
+		return pools
+	}
+	sorted := append([]memPool{}, pools...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].AllocatedBytes > sorted[j].AllocatedBytes
+	})
+	return sorted[:opts.Top]
+}
+
+// memPoolMetrics 是每个内存池导出的指标
+var memPoolMetrics = []struct {
+	name  string
+	help  string
+	value func(memPool) float64
+}{
+	{"object_size_bytes", "Size of one object of the memory pool in bytes", func(p memPool) float64 { return p.ObjectSize }},
+	{"allocated_objects", "Number of objects allocated by the memory pool", func(p memPool) float64 { return p.Allocated }},
+	{"allocated_bytes", "Bytes allocated by the memory pool", func(p memPool) float64 { return p.AllocatedBytes }},
+	{"allocated_high_bytes", "High-water mark of bytes allocated by the memory pool", func(p memPool) float64 { return p.AllocatedHigh }},
+	{"in_use_objects", "Number of objects of the memory pool in use", func(p memPool) float64 { return p.InUse }},
+	{"in_use_bytes", "Bytes of the memory pool in use", func(p memPool) float64 { return p.InUseBytes }},
+	{"in_use_high_bytes", "High-water mark of bytes of the memory pool in use", func(p memPool) float64 { return p.InUseHigh }},
+	{"idle_objects", "Number of idle objects kept by the memory pool", func(p memPool) float64 { return p.Idle }},
+	{"idle_bytes", "Bytes of idle objects kept by the memory pool", func(p memPool) float64 { return p.IdleBytes }},
+	{"idle_high_bytes", "High-water mark of bytes of idle objects kept by the memory pool", func(p memPool) float64 { return p.IdleHigh }},
+}
+
+// GetSquidMemPools 返回 mgr:mem 的收集器
+func GetSquidMemPools(source *SnapshotSource, opts MemPoolOptions) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidMemPoolsCollector(source, opts)}
+}
+
+// SquidMemPoolsCollector 按内存池导出 mgr:mem 的统计信息，标签为 pool
+type SquidMemPoolsCollector struct {
+	source *SnapshotSource
+	opts   MemPoolOptions
+	descs  []*prometheus.Desc
+}
+
+// NewSquidMemPoolsCollector 创建新的内存池收集器
+func NewSquidMemPoolsCollector(source *SnapshotSource, opts MemPoolOptions) *SquidMemPoolsCollector {
+	collector := &SquidMemPoolsCollector{source: source, opts: opts}
+	for _, metric := range memPoolMetrics {
+		collector.descs = append(collector.descs, prometheus.NewDesc(
+			prometheus.BuildFQName("squid", "mem_pool", metric.name), metric.help, []string{"pool"}, nil))
+	}
+	return collector
+}
+
+// Describe 实现了Collector接口
+func (c *SquidMemPoolsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidMemPoolsCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(memPage)
+	if err != nil {
+		return
+	}
+
+	for _, pool := range selectMemPools(parseMemPools(lines), c.opts) {
+		for i, metric := range memPoolMetrics {
+			ch <- prometheus.MustNewConstMetric(c.descs[i], prometheus.GaugeValue, metric.value(pool), pool.Name)
+		}
+	}
+}
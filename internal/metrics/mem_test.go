@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析内存池表格
+func TestParseMemPools(t *testing.T) {
+	page := readPage(t, "mem")
+	memNode := memPool{
+		Name:           "mem_node",
+		ObjectSize:     4136,
+		Allocated:      62,
+		AllocatedBytes: 251 * 1024,
+		AllocatedHigh:  1277 * 1024,
+		InUse:          60,
+		InUseBytes:     243 * 1024,
+		InUseHigh:      1277 * 1024,
+		Idle:           2,
+		IdleBytes:      9 * 1024,
+		IdleHigh:       235 * 1024,
+	}
+	memBlob := memPool{
+		Name: "MemBlob", ObjectSize: 48,
+		Allocated: 682, AllocatedBytes: 32 * 1024, AllocatedHigh: 32 * 1024,
+		InUse: 410, InUseBytes: 20 * 1024, InUseHigh: 28 * 1024,
+		Idle: 272, IdleBytes: 13 * 1024, IdleHigh: 13 * 1024,
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []memPool
+	}{
+		{
+			name:  "分块和未分块的内存池",
+			lines: page,
+			expected: []memPool{
+				memNode,
+				{
+					Name: "Short Strings", ObjectSize: 40,
+					Allocated: 3920, AllocatedBytes: 154 * 1024, AllocatedHigh: 172 * 1024,
+					InUse: 3890, InUseBytes: 152 * 1024, InUseHigh: 172 * 1024,
+					Idle: 30, IdleBytes: 2 * 1024, IdleHigh: 53 * 1024,
+				},
+				memBlob,
+				{
+					Name: "4K Buffers", ObjectSize: 4096,
+					Allocated: 10, AllocatedBytes: 40 * 1024, AllocatedHigh: 80 * 1024,
+					InUse: 1, InUseBytes: 4 * 1024, InUseHigh: 80 * 1024,
+					Idle: 9, IdleBytes: 36 * 1024, IdleHigh: 76 * 1024,
+				},
+				{
+					Name: "cbdata ClientHttpRequest (24)", ObjectSize: 1192,
+					Allocated: 3, AllocatedBytes: 4 * 1024, AllocatedHigh: 14 * 1024,
+					InUse: 2, InUseBytes: 3 * 1024, InUseHigh: 14 * 1024,
+					Idle: 1, IdleBytes: 2 * 1024, IdleHigh: 12 * 1024,
+				},
+			},
+		},
+		{
+			name:     "忽略Total汇总行",
+			lines:    []string{page[1], page[2], page[8]},
+			expected: nil,
+		},
+		{
+			name: "字符串池之后的行",
+			lines: []string{
+				"String Pool\t Impact\t\t\n",
+				page[3],
+			},
+			expected: nil,
+		},
+		{
+			name:     "已分配数量不是数字",
+			lines:    []string{strings.Replace(page[3], "\t62\t", "\tmany\t", 1), page[5]},
+			expected: []memPool{memBlob},
+		},
+		{
+			name:     "同名内存池只保留第一个",
+			lines:    []string{page[3], strings.Replace(page[5], "MemBlob", "mem_node", 1)},
+			expected: []memPool{memNode},
+		},
+		{
+			name: "SMP模式下累加各进程的同名内存池",
+			lines: []string{
+				"by kid1 {\n", page[0], page[1], page[2], page[3], page[5], page[8], page[15], "} by kid1\n", "\n",
+				"by kid2 {\n", page[0], page[1], page[2], page[3], page[15], page[16], "} by kid2\n", "\n",
+			},
+			expected: []memPool{
+				{
+					Name: "mem_node", ObjectSize: 4136,
+					Allocated: 124, AllocatedBytes: 502 * 1024, AllocatedHigh: 2554 * 1024,
+					InUse: 120, InUseBytes: 486 * 1024, InUseHigh: 2554 * 1024,
+					Idle: 4, IdleBytes: 18 * 1024, IdleHigh: 470 * 1024,
+				},
+				memBlob,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseMemPools(tt.lines))
+		})
+	}
+}
+
+// 测试按 top-N 或允许列表选择内存池
+func TestSelectMemPools(t *testing.T) {
+	pools := parseMemPools(readPage(t, "mem"))
+
+	tests := []struct {
+		name     string
+		opts     MemPoolOptions
+		expected []string
+	}{
+		{
+			name:     "按已分配字节数取top-N",
+			opts:     MemPoolOptions{Top: 2},
+			expected: []string{"mem_node", "Short Strings"},
+		},
+		{
+			name:     "允许列表优先于top-N",
+			opts:     MemPoolOptions{Top: 1, Allow: []string{"4K Buffers", "missing"}},
+			expected: []string{"4K Buffers"},
+		},
+		{
+			name:     "未限制时导出全部内存池",
+			opts:     MemPoolOptions{},
+			expected: []string{"mem_node", "Short Strings", "MemBlob", "4K Buffers", "cbdata ClientHttpRequest (24)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, pool := range selectMemPools(pools, tt.opts) {
+				names = append(names, pool.Name)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+// 测试内存池收集器的输出
+func TestSquidMemPoolsCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{memPage: readPage(t, "mem")}))
+
+	expected := `
+# HELP squid_mem_pool_in_use_bytes Bytes of the memory pool in use
+# TYPE squid_mem_pool_in_use_bytes gauge
+squid_mem_pool_in_use_bytes{pool="mem_node"} 248832
+`
+	collector := NewSquidMemPoolsCollector(source, MemPoolOptions{Top: 1})
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "squid_mem_pool_in_use_bytes"))
+	assert.Equal(t, len(memPoolMetrics), testutil.CollectAndCount(collector), "每个内存池输出全部指标")
+}
@@ -5,6 +5,7 @@ package metrics
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"net"
@@ -22,7 +23,7 @@ type mockConnectionHandler struct {
 	mock.Mock
 }
 
-func (m *mockConnectionHandler) connect() (net.Conn, error) {
+func (m *mockConnectionHandler) connect(ctx context.Context) (net.Conn, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -373,7 +374,7 @@ func TestReadFromSquid(t *testing.T) {
 			// 这里我们不调用client.readFromSquid，而是测试发送HTTP请求和处理响应的逻辑
 
 			// 模拟连接
-			conn, err := client.ch.connect()
+			conn, err := client.ch.connect(context.Background())
 			assert.NoError(t, err, "连接应该成功")
 			assert.NotNil(t, conn, "连接不应为空")
 
@@ -447,8 +448,8 @@ func TestReadFromSquid(t *testing.T) {
 	}
 }
 
-// 测试读取行函数
-func TestReadLines(t *testing.T) {
+// 测试逐行读取管理页面的响应
+func TestFetchLines(t *testing.T) {
 	tests := []struct {
 		name          string
 		input         string
@@ -478,30 +479,23 @@ func TestReadLines(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reader := bufio.NewReader(strings.NewReader(tt.input))
-			linesChan := make(chan string)
-
-			// 并行读取行
-			go readLines(reader, linesChan)
-
-			// 收集所有行
-			var receivedLines []string
-			for line := range linesChan {
-				receivedLines = append(receivedLines, line)
-			}
-
-			// 验证结果
-			assert.Equal(t, len(tt.expectedLines), len(receivedLines), "行数应匹配")
-
-			// 逐行比较内容，避免索引越界
-			minLength := len(tt.expectedLines)
-			if len(receivedLines) < minLength {
-				minLength = len(receivedLines)
+			mockConn := newMockConn()
+			mockConn.On("Close").Return(nil)
+			mockHandler := new(mockConnectionHandler)
+			mockHandler.On("connect").Return(mockConn, nil)
+			client := &CacheObjectClient{
+				ch:          mockHandler,
+				transport:   TransportCacheObject,
+				readTimeout: timeout,
 			}
+			prepareMockResponse(mockConn, 200, tt.input)
 
-			for i := 0; i < minLength; i++ {
-				assert.Equal(t, tt.expectedLines[i], receivedLines[i], "行内容应匹配")
-			}
+			receivedLines := []string{}
+			err := client.fetchLines(context.Background(), "counters", func(line string) {
+				receivedLines = append(receivedLines, line)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLines, receivedLines, "行内容应匹配")
 		})
 	}
 }
@@ -695,3 +689,37 @@ func TestGlobalVariables(t *testing.T) {
 		assert.Equal(t, "1.0.0", Version, "全局版本应匹配")
 	})
 }
+
+// 测试抓取上下文超时时中断阻塞的读取
+func TestClientContextTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	// 接受连接但从不响应
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  "127.0.0.1",
+		Port:      addr.Port,
+		Transport: TransportHTTP,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.GetCountersContext(ctx)
+	assert.Error(t, err, "超时时应返回错误")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "错误应包含上下文超时")
+	assert.Less(t, time.Since(start), 5*time.Second, "应在上下文截止时间附近返回")
+}
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pconnPage 是持久连接统计信息所在的管理页面
+const pconnPage = "pconn"
+
+// pconnHistogramSuffix 是每个连接池请求数直方图的标题后缀，例如 "server-peers persistent connection counts:"
+const pconnHistogramSuffix = " persistent connection counts:"
+
+// pconnBuckets 是每个连接处理的请求数直方图的桶上限
+var pconnBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// pconnPool 是 mgr:pconn 中一个连接池的统计信息
+type pconnPool struct {
+	Name string
+	// Counts 为处理了指定请求数的连接数，键为请求数
+	Counts map[int]float64
+	// Idle 为空闲连接表中的目标。表的键为目标，同一目标的全部空闲连接保存在一个键下，
+	// 页面中没有每个目标的空闲连接数，因此只记录目标是否存在
+	Idle map[string]bool
+}
+
+// PconnOptions 控制 mgr:pconn 的导出，AggregateOnly 为true时不按目标输出空闲连接
+type PconnOptions struct {
+	AggregateOnly bool
+}
+
+// parsePconn 解析 mgr:pconn。每个连接池先输出 "<名称> persistent connection counts:" 和请求数直方图，
+// 直方图的行形如 "\t1\t12345"，随后的 "Pool N Hash Table" 中的 "item 0:\t10.0.0.1:80/example.com"
+// 为空闲连接表的键，每个目标只出现一次。SMP模式下每个进程各有自己的连接池，同名的连接池合并
+func parsePconn(lines []string) []pconnPool {
+	var pools []pconnPool
+	var current *pconnPool
+	index := map[string]int{}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, pconnHistogramSuffix) {
+			name := strings.ToLower(strings.TrimSuffix(trimmed, pconnHistogramSuffix))
+			i, ok := index[name]
+			if !ok {
+				i = len(pools)
+				index[name] = i
+				pools = append(pools, pconnPool{Name: name, Counts: map[int]float64{}, Idle: map[string]bool{}})
+			}
+			current = &pools[i]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "item ") {
+			if idx := strings.Index(trimmed, ":"); idx > 0 {
+				if destination := strings.TrimSpace(trimmed[idx+1:]); destination != "" {
+					current.Idle[destination] = true
+				}
+			}
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		if len(fields) != 2 {
+			continue
+		}
+		requests, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if count, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			current.Counts[requests] += count
+		}
+	}
+	return pools
+}
+
+// histogram 把连接池的直方图转换为累计桶、总数和请求数之和
+func (p pconnPool) histogram() (map[float64]uint64, uint64, float64) {
+	return countsHistogram(p.Counts, pconnBuckets)
+}
+
+// countsHistogram 把Squid按整数值统计的直方图（值为该整数出现的次数）转换为
+// Prometheus直方图的累计桶、总数和值之和
+func countsHistogram(counts map[int]float64, limits []float64) (map[float64]uint64, uint64, float64) {
+	buckets := make(map[float64]uint64, len(limits))
+	var count uint64
+	var sum float64
+	for value, n := range counts {
+		count += uint64(n)
+		sum += float64(value) * n
+		for _, limit := range limits {
+			if float64(value) <= limit {
+				buckets[limit] += uint64(n)
+			}
+		}
+	}
+	return buckets, count, sum
+}
+
+// GetSquidPconn 返回 mgr:pconn 的收集器
+func GetSquidPconn(source *SnapshotSource, opts PconnOptions) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidPconnCollector(source, opts)}
+}
+
+// SquidPconnCollector 把每个连接池的请求数直方图导出为Prometheus直方图，并导出有空闲连接的目标
+type SquidPconnCollector struct {
+	source       *SnapshotSource
+	opts         PconnOptions
+	requests     *prometheus.Desc
+	destinations *prometheus.Desc
+	idle         *prometheus.Desc
+}
+
+// NewSquidPconnCollector 创建新的持久连接收集器
+func NewSquidPconnCollector(source *SnapshotSource, opts PconnOptions) *SquidPconnCollector {
+	return &SquidPconnCollector{
+		source: source,
+		opts:   opts,
+		requests: prometheus.NewDesc("squid_pconn_requests_per_connection",
+			"Number of requests handled by each persistent connection of the pool", []string{"pool"}, nil),
+		destinations: prometheus.NewDesc("squid_pconn_idle_destinations",
+			"Number of destinations with idle persistent connections in the pool", []string{"pool"}, nil),
+		idle: prometheus.NewDesc("squid_pconn_idle_destination_info",
+			"Destination that has idle persistent connections in the pool, always 1", []string{"pool", "destination"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidPconnCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.destinations
+	if !c.opts.AggregateOnly {
+		ch <- c.idle
+	}
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidPconnCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(pconnPage)
+	if err != nil {
+		return
+	}
+
+	for _, pool := range parsePconn(lines) {
+		buckets, count, sum := pool.histogram()
+		ch <- prometheus.MustNewConstHistogram(c.requests, count, sum, buckets, pool.Name)
+		ch <- prometheus.MustNewConstMetric(c.destinations, prometheus.GaugeValue, float64(len(pool.Idle)), pool.Name)
+		if c.opts.AggregateOnly {
+			continue
+		}
+
+		destinations := make([]string, 0, len(pool.Idle))
+		for destination := range pool.Idle {
+			destinations = append(destinations, destination)
+		}
+		sort.Strings(destinations)
+		for _, destination := range destinations {
+			ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, 1, pool.Name, destination)
+		}
+	}
+}
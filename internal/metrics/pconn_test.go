@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析连接池的直方图和空闲连接表
+func TestParsePconn(t *testing.T) {
+	page := readPage(t, "pconn")
+	serverPeers := pconnPool{Name: "server-peers", Counts: map[int]float64{1: 120, 2: 40, 3: 12, 7: 1}, Idle: map[string]bool{
+		"93.184.216.34:80/example.com":   true,
+		"[2001:db8::80]:443/example.org": true,
+		"10.0.0.1:3128":                  true,
+	}}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []pconnPool
+	}{
+		{
+			name:     "请求数直方图和IPv4、IPv6空闲目标",
+			lines:    page,
+			expected: []pconnPool{serverPeers},
+		},
+		{
+			name:  "没有空闲连接的连接池",
+			lines: page[:12],
+			expected: []pconnPool{
+				{Name: "server-peers", Counts: serverPeers.Counts, Idle: map[string]bool{}},
+			},
+		},
+		{
+			name:  "还没有连接的连接池",
+			lines: append(append([]string{}, page[:6]...), "\n", page[11]),
+			expected: []pconnPool{
+				{Name: "server-peers", Counts: map[int]float64{}, Idle: map[string]bool{}},
+			},
+		},
+		{
+			name: "SMP模式下合并各进程的同名连接池",
+			lines: append(append(append([]string{"by kid1 {\n"}, page...), "} by kid1\n", "\n", "by kid2 {\n"),
+				page[1], page[2], page[6], "\t12\t2\n", page[11], "\t item 0:\t10.0.0.2:3128\n", page[14], "} by kid2\n", "\n"),
+			expected: []pconnPool{
+				{Name: "server-peers", Counts: map[int]float64{1: 240, 2: 40, 3: 12, 7: 1, 12: 2}, Idle: map[string]bool{
+					"93.184.216.34:80/example.com":   true,
+					"[2001:db8::80]:443/example.org": true,
+					"10.0.0.1:3128":                  true,
+					"10.0.0.2:3128":                  true,
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parsePconn(tt.lines))
+		})
+	}
+}
+
+// 测试把按整数值统计的直方图转换为累计桶
+func TestCountsHistogram(t *testing.T) {
+	tests := []struct {
+		name    string
+		counts  map[int]float64
+		buckets map[float64]uint64
+		count   uint64
+		sum     float64
+	}{
+		{
+			name:    "连接池的直方图",
+			counts:  parsePconn(readPage(t, "pconn"))[0].Counts,
+			buckets: map[float64]uint64{1: 120, 2: 160, 5: 172, 10: 173, 20: 173, 50: 173, 100: 173, 200: 173, 500: 173, 1000: 173},
+			count:   173,
+			sum:     243,
+		},
+		{
+			name:    "空直方图",
+			counts:  map[int]float64{},
+			buckets: map[float64]uint64{},
+		},
+		{
+			name:    "超过最大桶的值",
+			counts:  map[int]float64{2000: 3},
+			buckets: map[float64]uint64{},
+			count:   3,
+			sum:     6000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets, count, sum := countsHistogram(tt.counts, pconnBuckets)
+			assert.Equal(t, tt.buckets, buckets)
+			assert.Equal(t, tt.count, count)
+			assert.Equal(t, tt.sum, sum)
+		})
+	}
+}
+
+// 测试持久连接收集器的输出和只输出汇总值的模式
+func TestSquidPconnCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{pconnPage: readPage(t, "pconn")}))
+
+	expected := `
+# HELP squid_pconn_idle_destinations Number of destinations with idle persistent connections in the pool
+# TYPE squid_pconn_idle_destinations gauge
+squid_pconn_idle_destinations{pool="server-peers"} 3
+# HELP squid_pconn_idle_destination_info Destination that has idle persistent connections in the pool, always 1
+# TYPE squid_pconn_idle_destination_info gauge
+squid_pconn_idle_destination_info{destination="10.0.0.1:3128",pool="server-peers"} 1
+squid_pconn_idle_destination_info{destination="93.184.216.34:80/example.com",pool="server-peers"} 1
+squid_pconn_idle_destination_info{destination="[2001:db8::80]:443/example.org",pool="server-peers"} 1
+`
+	collector := NewSquidPconnCollector(source, PconnOptions{})
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_pconn_idle_destinations", "squid_pconn_idle_destination_info"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "squid_pconn_requests_per_connection"))
+
+	aggregate := NewSquidPconnCollector(source, PconnOptions{AggregateOnly: true})
+	assert.Equal(t, 0, testutil.CollectAndCount(aggregate, "squid_pconn_idle_destination_info"))
+	assert.Equal(t, 1, testutil.CollectAndCount(aggregate, "squid_pconn_idle_destinations"))
+}
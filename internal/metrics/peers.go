@@ -0,0 +1,233 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// serverListPage 是 cache_peer 统计信息所在的管理页面
+const serverListPage = "server_list"
+
+// peerTypes 把每个 peer 第一行的类型映射为 type 标签的值，类型名最多显示11个字符
+var peerTypes = map[string]string{
+	"Parent":      "parent",
+	"Sibling":     "sibling",
+	"Multicast G": "multicast",
+}
+
+// peerTimeLayouts 是 "Last failed connect() at:" 中时间的格式。Squid使用访问日志的本地时间格式，
+// 编译时启用 USE_GMT 时时区固定为 -000，较早的版本使用RFC1123格式
+var peerTimeLayouts = []string{
+	"02/Jan/2006:15:04:05 -0700",
+	"02/Jan/2006:15:04:05 -000",
+	time.RFC1123,
+}
+
+// peerStats 是 mgr:server_list 中一个 peer 的统计信息
+type peerStats struct {
+	Name        string
+	Host        string
+	Type        string
+	Up          bool
+	Fetches     float64
+	OpenConns   float64
+	RTT         float64
+	PingsSent   float64
+	PingsAcked  float64
+	Ignored     float64
+	LastFailure float64
+}
+
+// add 合并另一个进程中同名 peer 的统计信息，任一进程认为 peer 可用时视为可用，
+// RTT 和最近失败时间取较大的值
+func (p *peerStats) add(other peerStats) {
+	p.Up = p.Up || other.Up
+	p.Fetches += other.Fetches
+	p.OpenConns += other.OpenConns
+	p.RTT = max(p.RTT, other.RTT)
+	p.PingsSent += other.PingsSent
+	p.PingsAcked += other.PingsAcked
+	p.Ignored += other.Ignored
+	p.LastFailure = max(p.LastFailure, other.LastFailure)
+}
+
+// parsePeers 解析 mgr:server_list，SMP模式下合并各进程中同名的 peer
+func parsePeers(lines []string) []peerStats {
+	var peers []peerStats
+	index := map[string]int{}
+	for _, section := range kidSections(lines) {
+		seen := map[string]bool{}
+		for _, peer := range parsePeerSection(section) {
+			if seen[peer.Name] {
+				continue
+			}
+			seen[peer.Name] = true
+			if i, ok := index[peer.Name]; ok {
+				peers[i].add(peer)
+				continue
+			}
+			index[peer.Name] = len(peers)
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// parsePeerSection 解析一个进程的 peer 列表，每个 peer 以形如 "Parent     : parent1" 的行开始，
+// 其后为 "Host       : parent1.example.com/3128/3130" 等统计项
+func parsePeerSection(lines []string) []peerStats {
+	var peers []peerStats
+	var current *peerStats
+
+	for _, line := range lines {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+
+		if peerType, ok := peerTypes[key]; ok {
+			peers = append(peers, peerStats{Name: value, Host: value, Type: peerType})
+			current = &peers[len(peers)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "Host":
+			current.Host = strings.SplitN(value, "/", 2)[0]
+		case "Status":
+			current.Up = value == "Up"
+		case "FETCHES":
+			current.Fetches = peerNumber(value)
+		case "OPEN CONNS":
+			current.OpenConns = peerNumber(value)
+		case "AVG RTT":
+			current.RTT = peerNumber(value) / 1000
+		case "PINGS SENT":
+			current.PingsSent = peerNumber(value)
+		case "PINGS ACKED":
+			current.PingsAcked = peerNumber(value)
+		case "IGNORED":
+			current.Ignored = peerNumber(value)
+		case "Last failed connect() at":
+			t, err := parsePeerTime(value)
+			if err != nil {
+				logrus.Warnf("Ignoring last connect failure of peer %s: %v", current.Name, err)
+				continue
+			}
+			current.LastFailure = float64(t.Unix())
+		}
+	}
+	return peers
+}
+
+// parsePeerTime 按 peerTimeLayouts 依次尝试解析时间
+func parsePeerTime(value string) (time.Time, error) {
+	for _, layout := range peerTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// peerNumber 返回值中的第一个数字，例如 "98  98%" 中的98，无法解析时返回0
+func peerNumber(value string) float64 {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	number, _ := strconv.ParseFloat(fields[0], 64)
+	return number
+}
+
+// GetSquidPeers 返回 mgr:server_list 的收集器
+func GetSquidPeers(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidPeersCollector(source)}
+}
+
+// SquidPeersCollector 按 cache_peer 导出状态、请求数、连接数、ICP/HTCP查询数、RTT和最近一次连接失败的时间
+type SquidPeersCollector struct {
+	source      *SnapshotSource
+	up          *prometheus.Desc
+	fetches     *prometheus.Desc
+	openConns   *prometheus.Desc
+	rtt         *prometheus.Desc
+	pingsSent   *prometheus.Desc
+	pingsAcked  *prometheus.Desc
+	ignored     *prometheus.Desc
+	lastFailure *prometheus.Desc
+}
+
+// NewSquidPeersCollector 创建新的 cache_peer 收集器
+func NewSquidPeersCollector(source *SnapshotSource) *SquidPeersCollector {
+	labels := []string{"peer", "host", "type"}
+	return &SquidPeersCollector{
+		source: source,
+		up: prometheus.NewDesc("squid_peer_up",
+			"Whether the peer is considered up by Squid (1 for up, 0 for down)", labels, nil),
+		fetches: prometheus.NewDesc("squid_peer_fetches_total",
+			"Total number of requests forwarded to the peer", labels, nil),
+		openConns: prometheus.NewDesc("squid_peer_open_connections",
+			"Number of open connections to the peer", labels, nil),
+		rtt: prometheus.NewDesc("squid_peer_rtt_seconds",
+			"Average round trip time of ICP/HTCP queries to the peer in seconds", labels, nil),
+		pingsSent: prometheus.NewDesc("squid_peer_pings_sent_total",
+			"Total number of ICP/HTCP queries sent to the peer", labels, nil),
+		pingsAcked: prometheus.NewDesc("squid_peer_pings_acked_total",
+			"Total number of ICP/HTCP replies received from the peer", labels, nil),
+		ignored: prometheus.NewDesc("squid_peer_ignored_replies_total",
+			"Total number of ICP/HTCP replies from the peer that were ignored", labels, nil),
+		lastFailure: prometheus.NewDesc("squid_peer_last_connect_failure_timestamp_seconds",
+			"Time of the last failed connection to the peer in unix seconds", labels, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidPeersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.fetches
+	ch <- c.openConns
+	ch <- c.rtt
+	ch <- c.pingsSent
+	ch <- c.pingsAcked
+	ch <- c.ignored
+	ch <- c.lastFailure
+}
+
+// Collect实现了Collector接口，用于采集指标，没有连接失败过的 peer 不输出最近失败时间
+func (c *SquidPeersCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(serverListPage)
+	if err != nil {
+		return
+	}
+
+	for _, peer := range parsePeers(lines) {
+		labels := []string{peer.Name, peer.Host, peer.Type}
+		up := 0.0
+		if peer.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up, labels...)
+		ch <- prometheus.MustNewConstMetric(c.fetches, prometheus.CounterValue, peer.Fetches, labels...)
+		ch <- prometheus.MustNewConstMetric(c.openConns, prometheus.GaugeValue, peer.OpenConns, labels...)
+		ch <- prometheus.MustNewConstMetric(c.rtt, prometheus.GaugeValue, peer.RTT, labels...)
+		ch <- prometheus.MustNewConstMetric(c.pingsSent, prometheus.CounterValue, peer.PingsSent, labels...)
+		ch <- prometheus.MustNewConstMetric(c.pingsAcked, prometheus.CounterValue, peer.PingsAcked, labels...)
+		ch <- prometheus.MustNewConstMetric(c.ignored, prometheus.CounterValue, peer.Ignored, labels...)
+		if peer.LastFailure > 0 {
+			ch <- prometheus.MustNewConstMetric(c.lastFailure, prometheus.GaugeValue, peer.LastFailure, labels...)
+		}
+	}
+}
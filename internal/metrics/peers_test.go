@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析 cache_peer 统计信息
+func TestParsePeers(t *testing.T) {
+	page := readPage(t, "server_list")
+	parent := peerStats{Name: "parent1", Host: "10.0.0.1", Type: "parent", Up: true, Fetches: 120, OpenConns: 3,
+		RTT: 0.045, PingsSent: 500, PingsAcked: 480, Ignored: 2, LastFailure: 1735725600}
+	sibling := peerStats{Name: "sibling2", Host: "cache2.example.com", Type: "sibling", Fetches: 7}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []peerStats
+	}{
+		{
+			name:     "上级和同级缓存",
+			lines:    page,
+			expected: []peerStats{parent, sibling},
+		},
+		{
+			name:     "没有邻居",
+			lines:    []string{"There are no neighbors installed.\n"},
+			expected: nil,
+		},
+		{
+			name:     "no-query的同级缓存没有PINGS统计",
+			lines:    page[19:],
+			expected: []peerStats{sibling},
+		},
+		{
+			name: "组播组的类型名被截断",
+			lines: []string{
+				"Multicast G: 239.128.16.128\n",
+				"Host       : 239.128.16.128/3128/3130\n",
+				"PINGS SENT :       40\n",
+			},
+			expected: []peerStats{{Name: "239.128.16.128", Host: "239.128.16.128", Type: "multicast", PingsSent: 40}},
+		},
+		{
+			name: "无法解析的连接失败时间",
+			lines: []string{
+				page[1],
+				page[5],
+				"Last failed connect() at: yesterday\n",
+			},
+			expected: []peerStats{{Name: "parent1", Host: "parent1", Type: "parent", Up: true}},
+		},
+		{
+			name: "SMP模式下合并各进程的同名peer",
+			lines: append(append(append([]string{"by kid1 {\n"}, page...), "} by kid1\n", "\n", "by kid2 {\n"),
+				page[1], page[2], "Status     : Down\n", "FETCHES    : 30\n", "OPEN CONNS : 1\n", "AVG RTT    : 60 msec\n",
+				"Last failed connect() at: 01/Jan/2025:17:00:00 +0800\n", "} by kid2\n", "\n"),
+			expected: []peerStats{
+				{Name: "parent1", Host: "10.0.0.1", Type: "parent", Up: true, Fetches: 150, OpenConns: 4,
+					RTT: 0.06, PingsSent: 500, PingsAcked: 480, Ignored: 2, LastFailure: 1735725600},
+				sibling,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parsePeers(tt.lines))
+		})
+	}
+}
+
+// 测试解析最近一次连接失败的时间
+func TestParsePeerTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int64
+		wantErr  bool
+	}{
+		{name: "httpd本地时间", value: "01/Jan/2025:18:00:00 +0800", expected: 1735725600},
+		{name: "httpd格林尼治时间", value: "01/Jan/2025:10:00:00 -000", expected: 1735725600},
+		{name: "RFC1123", value: "Wed, 01 Jan 2025 10:00:00 GMT", expected: 1735725600},
+		{name: "未知格式", value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parsePeerTime(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, parsed.Unix())
+		})
+	}
+}
+
+// 测试 cache_peer 收集器的输出
+func TestSquidPeersCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{serverListPage: readPage(t, "server_list")}))
+
+	expected := `
+# HELP squid_peer_last_connect_failure_timestamp_seconds Time of the last failed connection to the peer in unix seconds
+# TYPE squid_peer_last_connect_failure_timestamp_seconds gauge
+squid_peer_last_connect_failure_timestamp_seconds{host="10.0.0.1",peer="parent1",type="parent"} 1.7357256e+09
+# HELP squid_peer_up Whether the peer is considered up by Squid (1 for up, 0 for down)
+# TYPE squid_peer_up gauge
+squid_peer_up{host="10.0.0.1",peer="parent1",type="parent"} 1
+squid_peer_up{host="cache2.example.com",peer="sibling2",type="sibling"} 0
+`
+	collector := NewSquidPeersCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_peer_up", "squid_peer_last_connect_failure_timestamp_seconds"))
+	assert.Equal(t, 2, testutil.CollectAndCount(collector, "squid_peer_fetches_total"))
+}
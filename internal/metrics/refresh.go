@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// refreshPage 是新鲜度检查统计信息所在的管理页面
+const refreshPage = "refresh"
+
+// refreshHistogramSuffix 是每个协议新鲜度直方图的标题后缀，例如 "HTTP histogram:"
+const refreshHistogramSuffix = " histogram:"
+
+// refreshCheck 是某个协议按结论和原因统计的新鲜度检查次数
+type refreshCheck struct {
+	Protocol string
+	Status   string
+	Reason   string
+	Count    float64
+}
+
+// refreshPattern 是一条 refresh_pattern 规则的匹配次数和被测试的次数
+type refreshPattern struct {
+	Pattern string
+	Matches float64
+	Tests   float64
+}
+
+// refreshStats 是 mgr:refresh 的解析结果
+type refreshStats struct {
+	Patterns []refreshPattern
+	Checks   []refreshCheck
+}
+
+// refreshLabel 把协议名或原因转换为标签值，例如 "Cache Digests" 转换为 cache_digests
+func refreshLabel(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(replaceNonAlphanumeric(s)), func(c rune) bool {
+		return c == '_'
+	})
+	return strings.Join(words, "_")
+}
+
+// parseRefresh 解析 mgr:refresh。"Refresh pattern usage:" 表格中每行为一条规则，
+// 每个协议的 "<协议> histogram:" 中的行形如 "   120\t 40.00\tFresh: expires time not reached"，
+// 以 TOTAL 行结束，总数可以由各原因的次数相加得到，不单独保存
+func parseRefresh(lines []string) refreshStats {
+	var stats refreshStats
+	section, protocol := "", ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case trimmed == "Refresh pattern usage:":
+			section = "patterns"
+			continue
+		case strings.HasSuffix(trimmed, refreshHistogramSuffix):
+			section = "histogram"
+			protocol = refreshLabel(strings.TrimSuffix(trimmed, refreshHistogramSuffix))
+			continue
+		case strings.HasPrefix(trimmed, "RefreshCheck"):
+			section = ""
+			continue
+		}
+
+		fields := strings.SplitN(trimmed, "\t", 4)
+		switch section {
+		case "patterns":
+			if len(fields) < 4 {
+				continue
+			}
+			matches, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+			if err != nil {
+				continue
+			}
+			tests, _ := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+			stats.Patterns = append(stats.Patterns, refreshPattern{Pattern: strings.TrimSpace(fields[3]), Matches: matches, Tests: tests})
+		case "histogram":
+			if len(fields) < 3 {
+				continue
+			}
+			count, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+			if err != nil {
+				continue
+			}
+			category := strings.TrimSpace(strings.Join(fields[2:], "\t"))
+			status, reason, ok := strings.Cut(category, ":")
+			if !ok {
+				continue
+			}
+			stats.Checks = append(stats.Checks, refreshCheck{
+				Protocol: protocol,
+				Status:   refreshLabel(status),
+				Reason:   refreshLabel(reason),
+				Count:    count,
+			})
+		}
+	}
+	return stats
+}
+
+// GetSquidRefresh 返回 mgr:refresh 的收集器
+func GetSquidRefresh(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidRefreshCollector(source)}
+}
+
+// SquidRefreshCollector 按协议、结论（fresh或stale）和原因导出新鲜度检查次数，以及每条 refresh_pattern 的匹配次数
+type SquidRefreshCollector struct {
+	source         *SnapshotSource
+	checks         *prometheus.Desc
+	patternMatches *prometheus.Desc
+	patternTests   *prometheus.Desc
+}
+
+// NewSquidRefreshCollector 创建新的新鲜度检查收集器
+func NewSquidRefreshCollector(source *SnapshotSource) *SquidRefreshCollector {
+	return &SquidRefreshCollector{
+		source: source,
+		checks: prometheus.NewDesc("squid_refresh_checks_total",
+			"Total number of freshness checks by protocol, outcome and reason", []string{"protocol", "status", "reason"}, nil),
+		patternMatches: prometheus.NewDesc("squid_refresh_pattern_matches_total",
+			"Total number of freshness checks that matched the refresh_pattern", []string{"pattern"}, nil),
+		patternTests: prometheus.NewDesc("squid_refresh_pattern_tests_total",
+			"Total number of times the refresh_pattern was tested", []string{"pattern"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidRefreshCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.checks
+	ch <- c.patternMatches
+	ch <- c.patternTests
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidRefreshCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(refreshPage)
+	if err != nil {
+		return
+	}
+	stats := parseRefresh(lines)
+
+	// SMP模式下每个进程各有一份统计，相同的规则和检查结果累加后输出
+	var patterns []string
+	matches := map[string]float64{}
+	tests := map[string]float64{}
+	for _, pattern := range stats.Patterns {
+		if _, ok := matches[pattern.Pattern]; !ok {
+			patterns = append(patterns, pattern.Pattern)
+		}
+		matches[pattern.Pattern] += pattern.Matches
+		tests[pattern.Pattern] += pattern.Tests
+	}
+	for _, pattern := range patterns {
+		ch <- prometheus.MustNewConstMetric(c.patternMatches, prometheus.CounterValue, matches[pattern], pattern)
+		ch <- prometheus.MustNewConstMetric(c.patternTests, prometheus.CounterValue, tests[pattern], pattern)
+	}
+
+	var keys []refreshCheck
+	checks := map[refreshCheck]float64{}
+	for _, check := range stats.Checks {
+		key := refreshCheck{Protocol: check.Protocol, Status: check.Status, Reason: check.Reason}
+		if _, ok := checks[key]; !ok {
+			keys = append(keys, key)
+		}
+		checks[key] += check.Count
+	}
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(c.checks, prometheus.CounterValue, checks[key], key.Protocol, key.Status, key.Reason)
+	}
+}
@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析新鲜度检查统计信息
+func TestParseRefresh(t *testing.T) {
+	page := readPage(t, "refresh")
+
+	tests := []struct {
+		name     string
+		lines    []string
+		patterns []refreshPattern
+		checks   int
+		nonZero  []refreshCheck
+	}{
+		{
+			name:  "HTTP和On Store的直方图",
+			lines: page,
+			patterns: []refreshPattern{
+				{Pattern: "^ftp:", Matches: 0, Tests: 0},
+				{Pattern: "-i (/cgi-bin/|\\?)", Matches: 0, Tests: 0},
+				{Pattern: ".", Matches: 1520, Tests: 1540},
+			},
+			checks: 28,
+			nonZero: []refreshCheck{
+				{Protocol: "http", Status: "fresh", Reason: "expires_time_not_reached", Count: 300},
+				{Protocol: "http", Status: "fresh", Reason: "refresh_pattern_last_mod_factor_percentage", Count: 900},
+				{Protocol: "http", Status: "stale", Reason: "expires_time_reached", Count: 100},
+				{Protocol: "http", Status: "stale", Reason: "by_default", Count: 240},
+				{Protocol: "on_store", Status: "fresh", Reason: "expires_time_not_reached", Count: 10},
+				{Protocol: "on_store", Status: "fresh", Reason: "refresh_pattern_last_mod_factor_percentage", Count: 200},
+			},
+		},
+		{
+			name:  "不区分大小写的规则保留 -i 前缀",
+			lines: page[1:7],
+			patterns: []refreshPattern{
+				{Pattern: "^ftp:", Matches: 0, Tests: 0},
+				{Pattern: "-i (/cgi-bin/|\\?)", Matches: 0, Tests: 0},
+				{Pattern: ".", Matches: 1520, Tests: 1540},
+			},
+		},
+		{
+			name:  "按协议的调用次数表被忽略",
+			lines: page[7:19],
+		},
+		{
+			name: "TOTAL行不计入检查次数",
+			lines: []string{
+				"Cache Digests histogram:\n",
+				"Count\t%Total\tCategory\n",
+				"     5\t100.00\tStale: by default\n",
+				"     5\t100.00\tTOTAL\n",
+			},
+			checks:  1,
+			nonZero: []refreshCheck{{Protocol: "cache_digests", Status: "stale", Reason: "by_default", Count: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := parseRefresh(tt.lines)
+			assert.Equal(t, tt.patterns, stats.Patterns)
+			assert.Len(t, stats.Checks, tt.checks)
+
+			var nonZero []refreshCheck
+			for _, check := range stats.Checks {
+				if check.Count > 0 {
+					nonZero = append(nonZero, check)
+				}
+			}
+			assert.Equal(t, tt.nonZero, nonZero)
+		})
+	}
+}
+
+// 测试协议名和原因转换为标签值
+func TestRefreshLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "协议名", value: "Cache Digests", expected: "cache_digests"},
+		{name: "带连字符的原因", value: " refresh_pattern last-mod factor percentage", expected: "refresh_pattern_last_mod_factor_percentage"},
+		{name: "结论", value: "Stale", expected: "stale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, refreshLabel(tt.value))
+		})
+	}
+}
+
+// 测试新鲜度检查收集器的输出
+func TestSquidRefreshCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{refreshPage: readPage(t, "refresh")}))
+
+	expected := `
+# HELP squid_refresh_pattern_matches_total Total number of freshness checks that matched the refresh_pattern
+# TYPE squid_refresh_pattern_matches_total counter
+squid_refresh_pattern_matches_total{pattern="-i (/cgi-bin/|\\?)"} 0
+squid_refresh_pattern_matches_total{pattern="."} 1520
+squid_refresh_pattern_matches_total{pattern="^ftp:"} 0
+`
+	collector := NewSquidRefreshCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "squid_refresh_pattern_matches_total"))
+	assert.Equal(t, 28, testutil.CollectAndCount(collector, "squid_refresh_checks_total"), "两个协议各十四个原因")
+}
+
+// 测试SMP模式下收集器累加各进程的规则匹配次数和检查次数
+func TestSquidRefreshCollectorSMP(t *testing.T) {
+	page := readPage(t, "refresh")
+	var lines []string
+	for _, kid := range []string{"1", "2"} {
+		lines = append(lines, "by kid"+kid+" {\n")
+		lines = append(lines, page[1:7]...)
+		lines = append(lines, page[21], page[22], page[25], "} by kid"+kid+"\n", "\n")
+	}
+	source := NewSnapshotSource(newPageClient(map[string][]string{refreshPage: lines}))
+
+	expected := `
+# HELP squid_refresh_checks_total Total number of freshness checks by protocol, outcome and reason
+# TYPE squid_refresh_checks_total counter
+squid_refresh_checks_total{protocol="http",reason="expires_time_not_reached",status="fresh"} 600
+# HELP squid_refresh_pattern_matches_total Total number of freshness checks that matched the refresh_pattern
+# TYPE squid_refresh_pattern_matches_total counter
+squid_refresh_pattern_matches_total{pattern="-i (/cgi-bin/|\\?)"} 0
+squid_refresh_pattern_matches_total{pattern="."} 3040
+squid_refresh_pattern_matches_total{pattern="^ftp:"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewSquidRefreshCollector(source), strings.NewReader(expected),
+		"squid_refresh_checks_total", "squid_refresh_pattern_matches_total"))
+}
@@ -4,8 +4,11 @@ package metrics
 
 import (
 	"fmt"
-	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // 定义Squid服务时间指标类型
@@ -16,7 +19,7 @@ type squidServiceTimes struct {
 	Description string
 }
 
-// Squid服务时间指标列表
+// Squid服务时间指标列表，只用于兼容旧的指标名
 var squidServiceTimesList = []squidServiceTimes{
 	{"HTTP_Requests", "All", "5", "Service Time Percentiles 5min"},
 	{"HTTP_Requests", "All", "10", "Service Time Percentiles 5min"},
@@ -116,12 +119,13 @@ var squidServiceTimesList = []squidServiceTimes{
 	{"DNS_Lookups", "", "95", "Service Time Percentiles 5min"},
 }
 
-// GetSquidServiceTimes 返回所有Squid服务时间指标
-func GetSquidServiceTimes() []prometheus.Collector {
+// GetLegacySquidServiceTimes 返回旧的每个百分位一个指标的服务时间收集器，只包含5分钟窗口，
+// 用于迁移仪表盘期间保留旧的指标名
+func GetLegacySquidServiceTimes(source *SnapshotSource) []prometheus.Collector {
 	collectors := []prometheus.Collector{}
 	for _, serviceTime := range squidServiceTimesList {
 		collectors = append(collectors,
-			NewSquidServiceTime(serviceTime.Section, serviceTime.Counter, serviceTime.Suffix, serviceTime.Description))
+			NewSquidServiceTime(source, serviceTime.Section, serviceTime.Counter, serviceTime.Suffix, serviceTime.Description))
 	}
 	return collectors
 }
@@ -129,13 +133,14 @@ func GetSquidServiceTimes() []prometheus.Collector {
 // SquidServiceTime 是用于存储Squid服务时间的指标
 type SquidServiceTime struct {
 	*baseMetrics
+	source  *SnapshotSource
 	section string
 	counter string
 	suffix  string
 }
 
 // NewSquidServiceTime创建一个新的SquidServiceTime实例
-func NewSquidServiceTime(section, counter, suffix, help string) *SquidServiceTime {
+func NewSquidServiceTime(source *SnapshotSource, section, counter, suffix, help string) *SquidServiceTime {
 	var name string
 
 	if counter != "" {
@@ -150,6 +155,7 @@ func NewSquidServiceTime(section, counter, suffix, help string) *SquidServiceTim
 
 	return &SquidServiceTime{
 		baseMetrics: NewMetrics(name, help, []string{}),
+		source:      source,
 		section:     section,
 		counter:     counter,
 		suffix:      suffix,
@@ -163,21 +169,6 @@ func (sst *SquidServiceTime) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect实现了Collector接口，用于采集指标
 func (sst *SquidServiceTime) Collect(ch chan<- prometheus.Metric) {
-	// 创建一个客户端连接Squid服务器
-	client := NewCacheObjectClient(&CacheObjectRequest{
-		Hostname: GlobalHostname,
-		Port:     GlobalPort,
-		Login:    GlobalLogin,
-		Password: GlobalPassword,
-		Headers:  GlobalHeaders,
-	})
-
-	serviceTimes, err := client.GetServiceTimes()
-	if err != nil {
-		// 连接失败，记录错误并返回
-		return
-	}
-
 	// 构建预期的Key格式
 	var key string
 	if sst.counter != "" {
@@ -186,12 +177,99 @@ func (sst *SquidServiceTime) Collect(ch chan<- prometheus.Metric) {
 		key = fmt.Sprintf("%s_%s", sst.section, sst.suffix)
 	}
 
-	// 查找匹配的指标
+	// 从本次抓取的共享快照中查找匹配的指标
+	if value, ok := sst.source.Current().ServiceTime(key); ok {
+		ch <- prometheus.MustNewConstMetric(sst.baseMetrics.desc, prometheus.GaugeValue, value)
+	}
+}
+
+// 服务时间的统计窗口，对应 mgr:service_times 的两列
+const (
+	serviceTimeWindow5m  = "5m"
+	serviceTimeWindow60m = "60m"
+)
+
+// GetSquidServiceTimes 返回服务时间收集器，每个请求类别一个指标族，带 quantile 和 window 标签
+func GetSquidServiceTimes(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidServiceTimesCollector(source)}
+}
+
+// SquidServiceTimesCollector 把 mgr:service_times 的百分位导出为按类别划分的指标族
+type SquidServiceTimesCollector struct {
+	source *SnapshotSource
+	mu     sync.Mutex
+	descs  map[string]*prometheus.Desc
+}
+
+// NewSquidServiceTimesCollector 创建新的服务时间收集器
+func NewSquidServiceTimesCollector(source *SnapshotSource) *SquidServiceTimesCollector {
+	return &SquidServiceTimesCollector{
+		source: source,
+		descs:  map[string]*prometheus.Desc{},
+	}
+}
+
+// splitServiceTimeKey 把 HTTP_Requests_All_95 形式的键拆分为类别和分位数
+func splitServiceTimeKey(key string) (string, string, bool) {
+	idx := strings.LastIndex(key, "_")
+	if idx <= 0 {
+		return "", "", false
+	}
+	percent, err := strconv.ParseFloat(key[idx+1:], 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return "", "", false
+	}
+	return key[:idx], strconv.FormatFloat(percent/100, 'f', -1, 64), true
+}
+
+// desc 返回类别对应的指标描述，首次出现时创建
+func (c *SquidServiceTimesCollector) desc(category string) *prometheus.Desc {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if desc, ok := c.descs[category]; ok {
+		return desc
+	}
+	name := prometheus.BuildFQName("squid", "service_times",
+		strings.ToLower(replaceNonAlphanumeric(category))+"_seconds")
+	help := fmt.Sprintf("Service time percentiles of %s in seconds", strings.Replace(category, "_", " ", -1))
+	desc := prometheus.NewDesc(name, help, []string{"quantile", "window"}, nil)
+	c.descs[category] = desc
+	return desc
+}
+
+// Describe 实现了Collector接口，类别随Squid的输出动态变化，不预先描述
+func (c *SquidServiceTimesCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidServiceTimesCollector) Collect(ch chan<- prometheus.Metric) {
+	serviceTimes, err := c.source.Current().ServiceTimes()
+	if err != nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(serviceTimes))
 	for _, serviceTime := range serviceTimes {
-		if serviceTime.Key == key {
-			// 找到匹配的指标，使用实际数据
-			ch <- prometheus.MustNewConstMetric(sst.baseMetrics.desc, prometheus.GaugeValue, serviceTime.Value)
-			return
+		if seen[serviceTime.Key] {
+			continue
+		}
+		seen[serviceTime.Key] = true
+
+		category, quantile, ok := splitServiceTimeKey(serviceTime.Key)
+		if !ok {
+			continue
+		}
+		desc := c.desc(category)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, serviceTime.Value, quantile, serviceTimeWindow5m)
+
+		for _, label := range serviceTime.VarLabels {
+			if label.Key != "60min" {
+				continue
+			}
+			if value, err := strconv.ParseFloat(label.Value, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, quantile, serviceTimeWindow60m)
+			}
 		}
 	}
 }
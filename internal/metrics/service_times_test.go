@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 返回固定 mgr:service_times 页面的模拟客户端
+type serviceTimesClient struct {
+	lines []string
+}
+
+func (c *serviceTimesClient) GetCounters() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *serviceTimesClient) GetInfos() ([]Counter, error) {
+	return nil, nil
+}
+
+func (c *serviceTimesClient) GetServiceTimes() ([]Counter, error) {
+	var serviceTimes []Counter
+	for _, line := range c.lines {
+		serviceTime, err := decodeServiceTimeStrings(line)
+		if err == nil && serviceTime.Key != "" {
+			serviceTimes = append(serviceTimes, serviceTime)
+		}
+	}
+	return serviceTimes, nil
+}
+
+// 测试解析包含两个窗口的百分位行
+func TestDecodeServiceTimeStringsWindows(t *testing.T) {
+	serviceTime, err := decodeServiceTimeStrings("HTTP Requests (All):  95%   0.01035   0.02190\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "HTTP_Requests_All_95", serviceTime.Key)
+	assert.Equal(t, 0.01035, serviceTime.Value, "第一列为5分钟窗口")
+	assert.Equal(t, []VarLabel{{Key: "60min", Value: "0.02190"}}, serviceTime.VarLabels, "第二列为60分钟窗口")
+
+	serviceTime, err = decodeServiceTimeStrings("Cache Hits:            5%   0.00000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "Cache_Hits_5", serviceTime.Key)
+	assert.Empty(t, serviceTime.VarLabels, "只有一列时没有60分钟窗口")
+}
+
+// 测试拆分服务时间键
+func TestSplitServiceTimeKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		category string
+		quantile string
+		ok       bool
+	}{
+		{"HTTP_Requests_All_95", "HTTP_Requests_All", "0.95", true},
+		{"DNS_Lookups_5", "DNS_Lookups", "0.05", true},
+		{"HTTP_Requests_All_100", "HTTP_Requests_All", "1", true},
+		{"Near_Hits_abc", "", "", false},
+		{"Cache_Hits_150", "", "", false},
+		{"nounderscore", "", "", false},
+	}
+
+	for _, tt := range tests {
+		category, quantile, ok := splitServiceTimeKey(tt.key)
+		assert.Equal(t, tt.ok, ok, tt.key)
+		assert.Equal(t, tt.category, category, tt.key)
+		assert.Equal(t, tt.quantile, quantile, tt.key)
+	}
+}
+
+// 测试按类别导出带 quantile 和 window 标签的服务时间
+func TestSquidServiceTimesCollector(t *testing.T) {
+	client := &serviceTimesClient{lines: []string{
+		"Service Time Percentiles            5 min    60 min:\n",
+		"HTTP Requests (All):  50%   0.00865   0.01035\n",
+		"HTTP Requests (All):  95%   0.04519   0.05046\n",
+		"DNS Lookups:          50%   0.00094   0.00100\n",
+	}}
+	source := NewSnapshotSource(client)
+
+	expected := `
+# HELP squid_service_times_dns_lookups_seconds Service time percentiles of DNS Lookups in seconds
+# TYPE squid_service_times_dns_lookups_seconds gauge
+squid_service_times_dns_lookups_seconds{quantile="0.5",window="5m"} 0.00094
+squid_service_times_dns_lookups_seconds{quantile="0.5",window="60m"} 0.001
+# HELP squid_service_times_http_requests_all_seconds Service time percentiles of HTTP Requests All in seconds
+# TYPE squid_service_times_http_requests_all_seconds gauge
+squid_service_times_http_requests_all_seconds{quantile="0.5",window="5m"} 0.00865
+squid_service_times_http_requests_all_seconds{quantile="0.5",window="60m"} 0.01035
+squid_service_times_http_requests_all_seconds{quantile="0.95",window="5m"} 0.04519
+squid_service_times_http_requests_all_seconds{quantile="0.95",window="60m"} 0.05046
+`
+	collector := NewSquidServiceTimesCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+// 测试兼容模式下仍输出旧的指标名
+func TestLegacySquidServiceTimes(t *testing.T) {
+	client := &serviceTimesClient{lines: []string{
+		"HTTP Requests (All):  95%   0.04519   0.05046\n",
+	}}
+	source := NewSnapshotSource(client)
+
+	var found bool
+	for _, collector := range GetLegacySquidServiceTimes(source) {
+		if testutil.CollectAndCount(collector, "squid_HTTP_Requests_All_95") == 1 {
+			found = true
+			assert.Equal(t, 0.04519, testutil.ToFloat64(collector))
+		}
+	}
+	assert.True(t, found, "应输出旧的指标名")
+}
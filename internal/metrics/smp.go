@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// maxDetectedWorkers 探测worker数量时最多尝试的进程数
+	maxDetectedWorkers = 64
+	// processLabel 是SMP模式下区分进程的标签，聚合值为 processAll
+	processLabel = "process"
+	processAll   = "all"
+)
+
+// kidClientProvider 由能够请求单个SMP进程页面的客户端实现
+type kidClientProvider interface {
+	ForKid(kid int) SquidClient
+}
+
+// kidClient 通过 kidN/ 页面前缀请求单个worker进程的数据
+type kidClient struct {
+	client *CacheObjectClient
+	prefix string
+}
+
+// ForKid 返回只请求第kid个worker进程数据的客户端
+func (c *CacheObjectClient) ForKid(kid int) SquidClient {
+	return &kidClient{client: c, prefix: fmt.Sprintf("kid%d/", kid)}
+}
+
+func (k *kidClient) GetCounters() ([]Counter, error) {
+	return k.GetCountersContext(context.Background())
+}
+
+func (k *kidClient) GetServiceTimes() ([]Counter, error) {
+	return k.GetServiceTimesContext(context.Background())
+}
+
+func (k *kidClient) GetInfos() ([]Counter, error) {
+	return k.GetInfosContext(context.Background())
+}
+
+func (k *kidClient) GetCountersContext(ctx context.Context) ([]Counter, error) {
+	return k.client.getCounters(ctx, k.prefix)
+}
+
+func (k *kidClient) GetServiceTimesContext(ctx context.Context) ([]Counter, error) {
+	return k.client.getServiceTimes(ctx, k.prefix)
+}
+
+func (k *kidClient) GetInfosContext(ctx context.Context) ([]Counter, error) {
+	return k.client.getInfos(ctx, k.prefix)
+}
+
+func (k *kidClient) GetPageContext(ctx context.Context, page string) ([]string, error) {
+	return k.client.getPage(ctx, k.prefix, page)
+}
+
+// kidName 返回第kid个进程的 process 标签值
+func kidName(kid int) string {
+	return "kid" + strconv.Itoa(kid)
+}
+
+// perProcessMetric 输出同时包含聚合值和各worker进程值的指标
+type perProcessMetric struct {
+	desc        *prometheus.Desc
+	processDesc *prometheus.Desc
+	valueType   prometheus.ValueType
+}
+
+// newPerProcessMetric 创建指标，labels 为 process 之外的固定标签，例如 window
+func newPerProcessMetric(fqname, help string, valueType prometheus.ValueType, labels ...string) *perProcessMetric {
+	processLabels := append(append([]string{}, labels...), processLabel)
+	return &perProcessMetric{
+		desc:        prometheus.NewDesc(fqname, help, labels, nil),
+		processDesc: prometheus.NewDesc(fqname, help, processLabels, nil),
+		valueType:   valueType,
+	}
+}
+
+// collect 非SMP模式时只输出不带标签的聚合值；
+// SMP模式时聚合值带 process="all" 标签，各worker进程的值带 process="kidN" 标签。
+// labelValues 为创建指标时 labels 对应的值
+func (m *perProcessMetric) collect(ch chan<- prometheus.Metric, snapshot *Snapshot, lookup func(*Snapshot) (float64, bool), labelValues ...string) {
+	value, ok := lookup(snapshot)
+	workers := 1
+	if ok {
+		workers = snapshot.Workers()
+	}
+	if workers <= 1 {
+		if ok {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, value, labelValues...)
+		}
+		return
+	}
+
+	withProcess := func(process string) []string {
+		return append(append([]string{}, labelValues...), process)
+	}
+	ch <- prometheus.MustNewConstMetric(m.processDesc, m.valueType, value, withProcess(processAll)...)
+	for kid := 1; kid <= workers; kid++ {
+		kidSnapshot := snapshot.Kid(kid)
+		if kidSnapshot == nil {
+			return
+		}
+		if kidValue, ok := lookup(kidSnapshot); ok {
+			ch <- prometheus.MustNewConstMetric(m.processDesc, m.valueType, kidValue, withProcess(kidName(kid))...)
+		}
+	}
+}
+
+// pageValues 在一次收集中缓存各进程页面的解析结果，供 perProcessMetric 按键查找
+type pageValues struct {
+	page   string
+	parse  func(lines []string) map[string]float64
+	values map[*Snapshot]map[string]float64
+}
+
+func newPageValues(page string, parse func(lines []string) map[string]float64) *pageValues {
+	return &pageValues{page: page, parse: parse, values: map[*Snapshot]map[string]float64{}}
+}
+
+// get 返回快照中页面的解析结果，每个进程的页面只解析一次，请求失败时为空
+func (p *pageValues) get(snapshot *Snapshot) map[string]float64 {
+	values, ok := p.values[snapshot]
+	if !ok {
+		if lines, err := snapshot.Page(p.page); err == nil {
+			values = p.parse(lines)
+		}
+		p.values[snapshot] = values
+	}
+	return values
+}
+
+// lookup 返回按键查找的函数
+func (p *pageValues) lookup(key string) func(*Snapshot) (float64, bool) {
+	return func(snapshot *Snapshot) (float64, bool) {
+		value, ok := p.get(snapshot)[key]
+		return value, ok
+	}
+}
+
+// kidSections 拆分SMP模式下不能聚合的页面。这类页面中每个进程的输出位于
+// "by kidN {" 和 "} by kidN" 之间，非SMP模式的页面整体作为一个部分返回
+func kidSections(lines []string) [][]string {
+	var sections [][]string
+	var current []string
+	inKid := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "by kid") && strings.HasSuffix(trimmed, "{"):
+			inKid = true
+			current = nil
+		case strings.HasPrefix(trimmed, "} by kid"):
+			if inKid {
+				sections = append(sections, current)
+			}
+			inKid = false
+		case inKid:
+			current = append(current, line)
+		}
+	}
+	if sections == nil {
+		return [][]string{lines}
+	}
+	return sections
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 启动一个有两个worker的模拟SMP Squid
+func newSMPMgrServer(t *testing.T) (string, int) {
+	return newMgrPagesServer(t, map[string]string{
+		"/squid-internal-mgr/counters":      "client_http.requests = 30\n",
+		"/squid-internal-mgr/kid1/counters": "client_http.requests = 20\n",
+		"/squid-internal-mgr/kid2/counters": "client_http.requests = 10\n",
+	})
+}
+
+// 启动一个按路径返回页面的模拟Squid，返回监听的主机和端口
+func newMgrPagesServer(t *testing.T, pages map[string]string) (string, int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(server.Close)
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	assert.NoError(t, err)
+	return host, port
+}
+
+// 测试从缓存管理器探测worker数量并输出各进程的计数器
+func TestSMPCountersPerProcess(t *testing.T) {
+	host, port := newSMPMgrServer(t)
+	client := NewCacheObjectClient(&CacheObjectRequest{Hostname: host, Port: port, Transport: TransportHTTP})
+	source := NewSnapshotSource(client)
+
+	source.BeginScrape(context.Background())
+	defer source.EndScrape()
+	assert.Equal(t, 2, source.Current().Workers(), "应探测到两个worker")
+
+	counter := NewSquidCountersCollector(source)
+	expected := `
+# HELP squid_client_http_requests_total The total number of client requests
+# TYPE squid_client_http_requests_total counter
+squid_client_http_requests_total{process="all"} 30
+squid_client_http_requests_total{process="kid1"} 20
+squid_client_http_requests_total{process="kid2"} 10
+`
+	assert.NoError(t, testutil.CollectAndCompare(counter, strings.NewReader(expected)))
+
+	// 探测结果应缓存到下一次抓取
+	source.BeginScrape(context.Background())
+	assert.Equal(t, 2, source.knownWorkers(), "探测结果应被缓存")
+}
+
+// 测试非SMP模式时只输出不带标签的聚合值
+func TestSMPSingleWorker(t *testing.T) {
+	client := &countingSquidClient{}
+	source := NewSnapshotSource(client)
+	source.BeginScrape(context.Background())
+
+	counter := NewSquidCountersCollector(source)
+	ch := make(chan prometheus.Metric, 4)
+	counter.Collect(ch)
+	close(ch)
+
+	assert.Len(t, ch, 2, "每个计数器应只输出一个聚合值")
+	assert.Equal(t, 1, source.Current().Workers(), "不支持SMP页面的客户端应视为单进程")
+}
+
+// 测试squid.conf中配置的worker数量优先于探测
+func TestSMPConfiguredWorkers(t *testing.T) {
+	host, port := newSMPMgrServer(t)
+	client := NewCacheObjectClient(&CacheObjectRequest{Hostname: host, Port: port, Transport: TransportHTTP})
+	source := NewSnapshotSource(client)
+	source.SetWorkers(1)
+
+	source.BeginScrape(context.Background())
+	defer source.EndScrape()
+	assert.Equal(t, 1, source.Current().Workers(), "应使用配置的worker数量")
+}
+
+// 测试拆分SMP模式下各进程的页面输出
+func TestKidSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected [][]string
+	}{
+		{
+			name:     "非SMP页面",
+			lines:    []string{"Current memory usage:\n", "mem_node\t4136\n"},
+			expected: [][]string{{"Current memory usage:\n", "mem_node\t4136\n"}},
+		},
+		{
+			name: "两个进程",
+			lines: []string{
+				"by kid1 {\n", "Current memory usage:\n", "mem_node\t4136\n", "} by kid1\n", "\n",
+				"by kid2 {\n", "Current memory usage:\n", "} by kid2\n", "\n",
+			},
+			expected: [][]string{
+				{"Current memory usage:\n", "mem_node\t4136\n"},
+				{"Current memory usage:\n"},
+			},
+		},
+		{
+			name:     "进程输出为空",
+			lines:    []string{"by kid1 {\n", "} by kid1\n"},
+			expected: [][]string{nil},
+		},
+		{
+			name:     "缺少结束行",
+			lines:    []string{"by kid1 {\n", "} by kid1\n", "by kid2 {\n", "mem_node\t4136\n"},
+			expected: [][]string{nil},
+		},
+		{
+			name:     "空页面",
+			lines:    nil,
+			expected: [][]string{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, kidSections(tt.lines))
+		})
+	}
+}
@@ -0,0 +1,329 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errPageUnsupported 表示客户端不支持请求任意管理页面
+var errPageUnsupported = errors.New("squid client does not support cache manager pages")
+
+// snapshotPage 保存单个缓存管理器页面的解析结果，每个快照内最多请求一次
+type snapshotPage struct {
+	once   sync.Once
+	values []Counter
+	index  map[string]float64
+	err    error
+}
+
+// load 首次调用时请求页面并建立索引，之后直接返回缓存结果
+func (p *snapshotPage) load(fetch func() ([]Counter, error)) ([]Counter, error) {
+	p.once.Do(func() {
+		p.values, p.err = fetch()
+		p.index = make(map[string]float64, len(p.values))
+		for _, value := range p.values {
+			if _, ok := p.index[value.Key]; !ok {
+				p.index[value.Key] = value.Value
+			}
+		}
+	})
+	return p.values, p.err
+}
+
+// lookup 按键查找页面中的数值
+func (p *snapshotPage) lookup(fetch func() ([]Counter, error), key string) (float64, bool) {
+	if _, err := p.load(fetch); err != nil {
+		return 0, false
+	}
+	value, ok := p.index[key]
+	return value, ok
+}
+
+// Snapshot 表示一次抓取中Squid缓存管理器数据的一致视图
+// counters、info、service_times 各页面按需懒加载，且在同一快照内只请求一次
+type Snapshot struct {
+	ctx          context.Context
+	client       SquidClient
+	source       *SnapshotSource
+	counters     snapshotPage
+	infos        snapshotPage
+	serviceTimes snapshotPage
+
+	// pages 保存由各收集器自行解析的其他管理页面
+	pagesMu sync.Mutex
+	pages   map[string]*rawPage
+
+	// SMP模式下的worker数量和各worker进程的快照
+	workersOnce sync.Once
+	workers     int
+	kidsMu      sync.Mutex
+	kids        map[int]*Snapshot
+}
+
+// NewSnapshot 创建一个基于指定客户端的空快照
+func NewSnapshot(client SquidClient) *Snapshot {
+	return NewSnapshotContext(context.Background(), client)
+}
+
+// NewSnapshotContext 创建一个空快照，客户端支持上下文时页面请求受ctx的超时和取消控制
+func NewSnapshotContext(ctx context.Context, client SquidClient) *Snapshot {
+	return &Snapshot{ctx: ctx, client: client}
+}
+
+// fetchCounters 等方法在客户端支持上下文时使用快照的上下文请求页面
+func (s *Snapshot) fetchCounters() ([]Counter, error) {
+	if client, ok := s.client.(ContextSquidClient); ok {
+		return client.GetCountersContext(s.ctx)
+	}
+	return s.client.GetCounters()
+}
+
+func (s *Snapshot) fetchInfos() ([]Counter, error) {
+	if client, ok := s.client.(ContextSquidClient); ok {
+		return client.GetInfosContext(s.ctx)
+	}
+	return s.client.GetInfos()
+}
+
+func (s *Snapshot) fetchServiceTimes() ([]Counter, error) {
+	if client, ok := s.client.(ContextSquidClient); ok {
+		return client.GetServiceTimesContext(s.ctx)
+	}
+	return s.client.GetServiceTimes()
+}
+
+// Counters 返回 mgr:counters 页面的解析结果
+func (s *Snapshot) Counters() ([]Counter, error) {
+	return s.counters.load(s.fetchCounters)
+}
+
+// Infos 返回 mgr:info 页面的解析结果
+func (s *Snapshot) Infos() ([]Counter, error) {
+	return s.infos.load(s.fetchInfos)
+}
+
+// ServiceTimes 返回 mgr:service_times 页面的解析结果
+func (s *Snapshot) ServiceTimes() ([]Counter, error) {
+	return s.serviceTimes.load(s.fetchServiceTimes)
+}
+
+// rawPage 保存单个未解析的管理页面，每个快照内最多请求一次
+type rawPage struct {
+	once  sync.Once
+	lines []string
+	err   error
+}
+
+// Page 返回指定管理页面的全部行，同一快照内只请求一次
+func (s *Snapshot) Page(name string) ([]string, error) {
+	s.pagesMu.Lock()
+	if s.pages == nil {
+		s.pages = map[string]*rawPage{}
+	}
+	page, ok := s.pages[name]
+	if !ok {
+		page = &rawPage{}
+		s.pages[name] = page
+	}
+	s.pagesMu.Unlock()
+
+	page.once.Do(func() {
+		client, ok := s.client.(PageSquidClient)
+		if !ok {
+			page.err = errPageUnsupported
+			return
+		}
+		page.lines, page.err = client.GetPageContext(s.ctx, name)
+	})
+	return page.lines, page.err
+}
+
+// StreamPage 逐行处理指定管理页面，不在快照中缓存，每次调用都会请求一次页面。
+// 客户端不支持逐行处理时退回到 Page
+func (s *Snapshot) StreamPage(name string, handle func(line string)) error {
+	if client, ok := s.client.(StreamSquidClient); ok {
+		return client.StreamPageContext(s.ctx, name, handle)
+	}
+	lines, err := s.Page(name)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		handle(line)
+	}
+	return nil
+}
+
+// Counter 按键查找计数器的值
+func (s *Snapshot) Counter(key string) (float64, bool) {
+	return s.counters.lookup(s.fetchCounters, key)
+}
+
+// Info 按键查找信息指标的值
+func (s *Snapshot) Info(key string) (float64, bool) {
+	return s.infos.lookup(s.fetchInfos, key)
+}
+
+// ServiceTime 按键查找服务时间的值
+func (s *Snapshot) ServiceTime(key string) (float64, bool) {
+	return s.serviceTimes.lookup(s.fetchServiceTimes, key)
+}
+
+// Workers 返回SMP模式下的worker数量，不大于1时表示非SMP模式。
+// 优先使用squid.conf中配置的数量，否则依次请求 kidN/counters 探测，探测结果在快照源中缓存
+func (s *Snapshot) Workers() int {
+	s.workersOnce.Do(func() {
+		s.kids = map[int]*Snapshot{}
+		if s.source != nil {
+			if workers := s.source.knownWorkers(); workers > 0 {
+				s.workers = workers
+				return
+			}
+		}
+
+		provider, ok := s.client.(kidClientProvider)
+		if !ok {
+			s.workers = 1
+			return
+		}
+
+		workers := 0
+		for kid := 1; kid <= maxDetectedWorkers; kid++ {
+			snapshot := NewSnapshotContext(s.ctx, provider.ForKid(kid))
+			if _, err := snapshot.Counters(); err != nil {
+				break
+			}
+			s.kids[kid] = snapshot
+			workers = kid
+		}
+		if workers == 0 {
+			workers = 1
+		}
+		// 上下文已结束时的探测结果不可靠，不缓存
+		if s.source != nil && s.ctx.Err() == nil {
+			s.source.setDetectedWorkers(workers)
+		}
+		s.workers = workers
+	})
+	return s.workers
+}
+
+// Kid 返回第kid个worker进程的快照，客户端不支持SMP页面时返回nil
+func (s *Snapshot) Kid(kid int) *Snapshot {
+	s.Workers()
+
+	s.kidsMu.Lock()
+	defer s.kidsMu.Unlock()
+	if snapshot, ok := s.kids[kid]; ok {
+		return snapshot
+	}
+	provider, ok := s.client.(kidClientProvider)
+	if !ok {
+		return nil
+	}
+	snapshot := NewSnapshotContext(s.ctx, provider.ForKid(kid))
+	s.kids[kid] = snapshot
+	return snapshot
+}
+
+// SnapshotSource 在同一次抓取的所有收集器之间共享快照
+type SnapshotSource struct {
+	client       SquidClient
+	totalTimeout time.Duration
+	mu           sync.Mutex
+	current      *Snapshot
+	cancel       context.CancelFunc
+
+	// workers 为squid.conf中配置的worker数量，detected 为从缓存管理器探测到的数量
+	workers  int
+	detected int
+}
+
+// NewSnapshotSource 创建新的快照源
+func NewSnapshotSource(client SquidClient) *SnapshotSource {
+	return &SnapshotSource{client: client}
+}
+
+// SetTotalTimeout 设置单次抓取请求该目标的总超时时间，为0时只受抓取上下文限制
+func (s *SnapshotSource) SetTotalTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totalTimeout = d
+}
+
+// BeginScrape 丢弃上一次抓取的快照，在每次抓取开始时调用
+func (s *SnapshotSource) BeginScrape(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.totalTimeout > 0 {
+		ctx, s.cancel = context.WithTimeout(ctx, s.totalTimeout)
+	} else {
+		ctx, s.cancel = context.WithCancel(ctx)
+	}
+	s.current = s.newSnapshot(ctx)
+}
+
+// newSnapshot 创建属于该快照源的快照
+func (s *SnapshotSource) newSnapshot(ctx context.Context) *Snapshot {
+	snapshot := NewSnapshotContext(ctx, s.client)
+	snapshot.source = s
+	return snapshot
+}
+
+// SetWorkers 设置squid.conf中配置的worker数量，为0时从缓存管理器探测
+func (s *SnapshotSource) SetWorkers(workers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.workers = workers
+}
+
+// knownWorkers 返回已配置或已探测到的worker数量，未知时为0
+func (s *SnapshotSource) knownWorkers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workers > 0 {
+		return s.workers
+	}
+	return s.detected
+}
+
+// setDetectedWorkers 缓存探测到的worker数量
+func (s *SnapshotSource) setDetectedWorkers(workers int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detected = workers
+}
+
+// ResetDetectedWorkers 清除探测结果，下次抓取时重新探测，在某个worker的页面请求失败时调用
+func (s *SnapshotSource) ResetDetectedWorkers() {
+	s.setDetectedWorkers(0)
+}
+
+// EndScrape 释放本次抓取的上下文，快照中已获取的数据仍然可用
+func (s *SnapshotSource) EndScrape() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// Current 返回当前抓取的快照，尚未开始抓取时创建一个新快照
+func (s *SnapshotSource) Current() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		s.current = s.newSnapshot(context.Background())
+	}
+	return s.current
+}
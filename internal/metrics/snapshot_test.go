@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// 统计各页面请求次数的模拟客户端
+type countingSquidClient struct {
+	counterCalls     int
+	infoCalls        int
+	serviceTimeCalls int
+	err              error
+}
+
+func (c *countingSquidClient) GetCounters() ([]Counter, error) {
+	c.counterCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []Counter{
+		{Key: "client_http.requests", Value: float64(100 * c.counterCalls)},
+		{Key: "client_http.hits", Value: 40},
+	}, nil
+}
+
+func (c *countingSquidClient) GetInfos() ([]Counter, error) {
+	c.infoCalls++
+	return []Counter{{Key: "UP_Time", Value: 3600}}, c.err
+}
+
+func (c *countingSquidClient) GetServiceTimes() ([]Counter, error) {
+	c.serviceTimeCalls++
+	return []Counter{{Key: "Cache_Hits_50", Value: 0.002}}, c.err
+}
+
+// 测试同一快照内每个页面只请求一次
+func TestSnapshotFetchesEachPageOnce(t *testing.T) {
+	client := &countingSquidClient{}
+	snapshot := NewSnapshot(client)
+
+	for i := 0; i < 5; i++ {
+		value, ok := snapshot.Counter("client_http.requests")
+		assert.True(t, ok, "应找到计数器")
+		assert.Equal(t, 100.0, value, "计数器值应匹配")
+		_, ok = snapshot.Info("UP_Time")
+		assert.True(t, ok, "应找到信息指标")
+	}
+
+	_, ok := snapshot.Counter("not.exists")
+	assert.False(t, ok, "不存在的键不应找到")
+
+	assert.Equal(t, 1, client.counterCalls, "counters页面应只请求一次")
+	assert.Equal(t, 1, client.infoCalls, "info页面应只请求一次")
+	assert.Equal(t, 0, client.serviceTimeCalls, "未使用的页面不应请求")
+}
+
+// 测试快照在请求失败时不返回数据
+func TestSnapshotError(t *testing.T) {
+	client := &countingSquidClient{err: fmt.Errorf("连接错误")}
+	snapshot := NewSnapshot(client)
+
+	_, err := snapshot.Counters()
+	assert.Error(t, err, "应返回错误")
+	_, ok := snapshot.Counter("client_http.requests")
+	assert.False(t, ok, "请求失败时不应找到计数器")
+	assert.Equal(t, 1, client.counterCalls, "失败的页面也只请求一次")
+}
+
+// 测试快照源在每次抓取开始时刷新快照
+func TestSnapshotSourceBeginScrape(t *testing.T) {
+	client := &countingSquidClient{}
+	source := NewSnapshotSource(client)
+
+	collectors := GetSquidCounters(source)
+	scrape := func() int {
+		source.BeginScrape(context.Background())
+		ch := make(chan prometheus.Metric, 10)
+		for _, c := range collectors {
+			c.Collect(ch)
+		}
+		close(ch)
+		count := 0
+		for range ch {
+			count++
+		}
+		return count
+	}
+
+	assert.Equal(t, 2, scrape(), "应只输出快照中存在的计数器")
+	assert.Equal(t, 1, client.counterCalls, "一次抓取应只请求一次counters页面")
+
+	scrape()
+	assert.Equal(t, 2, client.counterCalls, "新的抓取应重新请求")
+	value, _ := source.Current().Counter("client_http.requests")
+	assert.Equal(t, 200.0, value, "应读取最新快照中的值")
+}
+
+// 记录请求上下文的模拟客户端
+type contextRecordingClient struct {
+	countingSquidClient
+	ctx context.Context
+}
+
+func (c *contextRecordingClient) GetCountersContext(ctx context.Context) ([]Counter, error) {
+	c.ctx = ctx
+	return c.GetCounters()
+}
+
+func (c *contextRecordingClient) GetServiceTimesContext(ctx context.Context) ([]Counter, error) {
+	c.ctx = ctx
+	return c.GetServiceTimes()
+}
+
+func (c *contextRecordingClient) GetInfosContext(ctx context.Context) ([]Counter, error) {
+	c.ctx = ctx
+	return c.GetInfos()
+}
+
+// 测试快照源把抓取上下文和总超时传递给客户端
+func TestSnapshotSourceContext(t *testing.T) {
+	client := &contextRecordingClient{}
+	source := NewSnapshotSource(client)
+	source.SetTotalTimeout(time.Minute)
+
+	source.BeginScrape(context.Background())
+	_, err := source.Current().Counters()
+	assert.NoError(t, err)
+	assert.NotNil(t, client.ctx, "应使用上下文请求页面")
+
+	deadline, ok := client.ctx.Deadline()
+	assert.True(t, ok, "应设置总超时")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+
+	source.EndScrape()
+	assert.ErrorIs(t, client.ctx.Err(), context.Canceled, "抓取结束后应取消上下文")
+}
@@ -3,8 +3,11 @@
 package metrics
 
 import (
-	"github.com/prometheus/client_golang/prometheus"
+	"context"
 	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type SquidConfig struct {
@@ -14,15 +17,32 @@ type SquidConfig struct {
 	Password     string
 	Headers      []string
 	ExtractTimes bool
+	Transport    string
+	MgrPath      string
+	// TLS 不为空时通过TLS连接缓存管理器
+	TLS *TLSOptions
+	// DialTimeout、ReadTimeout 和 TotalTimeout 分别限制建立连接、读取单个页面和单次抓取的时间
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	TotalTimeout time.Duration
+	// Workers 为squid.conf中配置的SMP worker数量，为0时从缓存管理器探测
+	Workers int
+}
+
+// tlsStatusReporter 由能够报告TLS握手结果的客户端实现
+type tlsStatusReporter interface {
+	TLSStatus() TLSStatus
 }
 
 // SquidCollector 是主Squid指标收集器
 type SquidCollector struct {
 	client       SquidClient
+	source       *SnapshotSource
 	hostname     string
 	port         int
 	extractTimes bool
 	up           prometheus.Gauge
+	tls          *tlsMetrics
 }
 
 // NewSquidCollector 创建一个新的Squid指标收集器
@@ -33,33 +53,76 @@ func NewSquidCollector(config *SquidConfig) *SquidCollector {
 		Help:      "Was the last query of squid successful",
 	})
 
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:    config.Hostname,
+		Port:        config.Port,
+		Login:       config.Login,
+		Password:    config.Password,
+		Headers:     config.Headers,
+		Transport:   config.Transport,
+		MgrPath:     config.MgrPath,
+		TLS:         config.TLS,
+		DialTimeout: config.DialTimeout,
+		ReadTimeout: config.ReadTimeout,
+	})
+
 	collector := &SquidCollector{
-		client: NewCacheObjectClient(&CacheObjectRequest{
-			Hostname: config.Hostname,
-			Port:     config.Port,
-			Login:    config.Login,
-			Password: config.Password,
-			Headers:  config.Headers,
-		}),
+		client:       client,
+		source:       NewSnapshotSource(client),
 		hostname:     config.Hostname,
 		port:         config.Port,
 		extractTimes: config.ExtractTimes,
 		up:           up,
 	}
+	collector.source.SetTotalTimeout(config.TotalTimeout)
+	collector.source.SetWorkers(config.Workers)
+	if config.TLS != nil {
+		collector.tls = newTLSMetrics()
+	}
 
 	return collector
 }
 
+// Source 返回供其他收集器共享的快照源
+func (sc *SquidCollector) Source() *SnapshotSource {
+	return sc.source
+}
+
+// BeginScrape 在每次抓取开始时重置共享快照，ctx 控制本次抓取的超时和取消
+func (sc *SquidCollector) BeginScrape(ctx context.Context) {
+	if sc.source != nil {
+		sc.source.BeginScrape(ctx)
+	}
+}
+
+// EndScrape 在每次抓取结束时释放抓取上下文
+func (sc *SquidCollector) EndScrape() {
+	if sc.source != nil {
+		sc.source.EndScrape()
+	}
+}
+
+// snapshot 返回本次抓取使用的快照
+func (sc *SquidCollector) snapshot() *Snapshot {
+	if sc.source != nil {
+		return sc.source.Current()
+	}
+	return NewSnapshot(sc.client)
+}
+
 // Describe 实现了Collector接口
 func (sc *SquidCollector) Describe(ch chan<- *prometheus.Desc) {
-	// 只描述up指标
 	sc.up.Describe(ch)
+	if sc.tls != nil {
+		sc.tls.describe(ch)
+	}
 }
 
 // Collect 实现了Collector接口
 func (sc *SquidCollector) Collect(ch chan<- prometheus.Metric) {
-	// 尝试连接Squid服务器以检查状态
-	_, err := sc.client.GetCounters()
+	// 尝试获取本次抓取的计数器以检查状态，结果会被其他收集器复用
+	snapshot := sc.snapshot()
+	_, err := snapshot.Counters()
 
 	if err == nil {
 		// 连接成功，设置up指标为1
@@ -72,4 +135,33 @@ func (sc *SquidCollector) Collect(ch chan<- prometheus.Metric) {
 
 	// 发送up指标
 	ch <- sc.up
+
+	// SMP模式下某个worker的页面请求失败时，下次抓取重新探测worker数量
+	if err == nil && sc.source != nil {
+		sc.checkWorkers(snapshot)
+	}
+
+	// 发送TLS握手指标
+	if reporter, ok := sc.client.(tlsStatusReporter); ok && sc.tls != nil {
+		sc.tls.collect(ch, reporter.TLSStatus())
+	}
+}
+
+// checkWorkers 检查各worker进程的计数器页面，失败时清除探测到的worker数量
+func (sc *SquidCollector) checkWorkers(snapshot *Snapshot) {
+	workers := snapshot.Workers()
+	if workers <= 1 {
+		return
+	}
+	for kid := 1; kid <= workers; kid++ {
+		kidSnapshot := snapshot.Kid(kid)
+		if kidSnapshot == nil {
+			return
+		}
+		if _, err := kidSnapshot.Counters(); err != nil {
+			log.Printf("Error fetching counters of squid %s: %v", kidName(kid), err)
+			sc.source.ResetDetectedWorkers()
+			return
+		}
+	}
 }
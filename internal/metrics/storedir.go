@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// storeDirPage 是 cache_dir 统计信息所在的管理页面
+const storeDirPage = "storedir"
+
+// storeDir 是 mgr:storedir 中一个 cache_dir 的统计信息
+type storeDir struct {
+	Type   string
+	Path   string
+	Values map[string]float64
+	Flags  []string
+}
+
+// storeDirMetrics 是每个 cache_dir 导出的数值指标，key 为 storeDir.Values 中的键
+var storeDirMetrics = []struct {
+	key  string
+	name string
+	help string
+}{
+	{"size", "size_bytes", "Current size of the cache_dir in bytes"},
+	{"max_size", "max_size_bytes", "Maximum size of the cache_dir in bytes"},
+	{"capacity", "capacity_percent", "Percentage of the cache_dir maximum size in use"},
+	{"filemap_used", "filemap_used", "Number of filemap bits in use"},
+	{"filemap_size", "filemap_size", "Total number of filemap bits"},
+	{"entries", "entries", "Number of entries stored in the cache_dir"},
+	{"max_entries", "max_entries", "Maximum number of entries of the cache_dir"},
+	{"fs_used", "filesystem_used_bytes", "Space in use on the filesystem holding the cache_dir, in bytes"},
+	{"fs_size", "filesystem_size_bytes", "Size of the filesystem holding the cache_dir, in bytes"},
+}
+
+// parseStoreDirs 解析 mgr:storedir 页面，每个 "Store Directory #0 (aufs): /var/spool/squid" 开始一个 cache_dir
+func parseStoreDirs(lines []string) []storeDir {
+	var dirs []storeDir
+	var current *storeDir
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Store Directory #") {
+			dir, ok := parseStoreDirHeader(line)
+			if !ok {
+				current = nil
+				continue
+			}
+			dirs = append(dirs, dir)
+			current = &dirs[len(dirs)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		fields := strings.Fields(line[idx+1:])
+		if len(fields) == 0 {
+			continue
+		}
+		parseStoreDirLine(current, key, fields)
+	}
+
+	for i := range dirs {
+		values := dirs[i].Values
+		if _, ok := values["capacity"]; !ok && values["max_size"] > 0 {
+			if size, ok := values["size"]; ok {
+				values["capacity"] = size / values["max_size"] * 100
+			}
+		}
+		if _, ok := values["entries"]; !ok {
+			if used, ok := values["filemap_used"]; ok {
+				values["entries"] = used
+			}
+		}
+	}
+	return dirs
+}
+
+// parseStoreDirHeader 解析 "Store Directory #0 (aufs): /var/spool/squid" 形式的标题行
+func parseStoreDirHeader(line string) (storeDir, bool) {
+	open := strings.Index(line, "(")
+	closing := strings.Index(line, "):")
+	if open < 0 || closing < open {
+		return storeDir{}, false
+	}
+	return storeDir{
+		Type:   line[open+1 : closing],
+		Path:   strings.TrimSpace(line[closing+2:]),
+		Values: map[string]float64{},
+	}, true
+}
+
+// parseStoreDirLine 解析 cache_dir 中的一行，大小统一换算为字节
+func parseStoreDirLine(dir *storeDir, key string, fields []string) {
+	switch key {
+	case "Maximum Size":
+		setStoreDirValue(dir, "max_size", fields[0], 1024)
+	case "Current Size":
+		setStoreDirValue(dir, "size", fields[0], 1024)
+		// rock 形如 "Current Size: 123456.00 KB 11.77%"
+		if len(fields) > 2 && strings.HasSuffix(fields[2], "%") {
+			setStoreDirValue(dir, "capacity", strings.TrimSuffix(fields[2], "%"), 1)
+		}
+	case "Percent Used":
+		setStoreDirValue(dir, "capacity", strings.TrimSuffix(fields[0], "%"), 1)
+	case "Filemap bits in use":
+		// "12345 of 16384 (75%)"
+		setStoreDirValue(dir, "filemap_used", fields[0], 1)
+		if len(fields) > 2 && fields[1] == "of" {
+			setStoreDirValue(dir, "filemap_size", fields[2], 1)
+		}
+	case "Current entries":
+		setStoreDirValue(dir, "entries", fields[0], 1)
+	case "Maximum entries":
+		setStoreDirValue(dir, "max_entries", fields[0], 1)
+	case "Filesystem Space in use":
+		// "5000000/20000000 KB (25%)"
+		if used, size, ok := strings.Cut(fields[0], "/"); ok {
+			setStoreDirValue(dir, "fs_used", used, 1024)
+			setStoreDirValue(dir, "fs_size", size, 1024)
+		}
+	case "Flags":
+		dir.Flags = append(dir.Flags, fields...)
+	}
+}
+
+// setStoreDirValue 保存数值，无法解析时忽略
+func setStoreDirValue(dir *storeDir, key, value string, scale float64) {
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		dir.Values[key] = number * scale
+	}
+}
+
+// GetSquidStoreDirs 返回 mgr:storedir 的收集器
+func GetSquidStoreDirs(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidStoreDirCollector(source)}
+}
+
+// SquidStoreDirCollector 按 cache_dir 导出存储指标，标签为 path 和 type
+type SquidStoreDirCollector struct {
+	source   *SnapshotSource
+	descs    map[string]*prometheus.Desc
+	readOnly *prometheus.Desc
+	flag     *prometheus.Desc
+}
+
+// NewSquidStoreDirCollector 创建新的 cache_dir 收集器
+func NewSquidStoreDirCollector(source *SnapshotSource) *SquidStoreDirCollector {
+	labels := []string{"path", "type"}
+	collector := &SquidStoreDirCollector{
+		source: source,
+		descs:  make(map[string]*prometheus.Desc, len(storeDirMetrics)),
+		readOnly: prometheus.NewDesc("squid_storedir_read_only",
+			"Whether the cache_dir is read-only (1) or not (0)", labels, nil),
+		flag: prometheus.NewDesc("squid_storedir_flag",
+			"Flags reported for the cache_dir, the value is always 1", append(labels, "flag"), nil),
+	}
+	for _, metric := range storeDirMetrics {
+		collector.descs[metric.key] = prometheus.NewDesc(
+			prometheus.BuildFQName("squid", "storedir", metric.name), metric.help, labels, nil)
+	}
+	return collector
+}
+
+// Describe 实现了Collector接口
+func (c *SquidStoreDirCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+	ch <- c.readOnly
+	ch <- c.flag
+}
+
+// Collect实现了Collector接口，用于采集指标
+func (c *SquidStoreDirCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(storeDirPage)
+	if err != nil {
+		return
+	}
+
+	// 同一路径只输出第一个
+	seen := map[string]bool{}
+	for _, dir := range parseStoreDirs(lines) {
+		if seen[dir.Path] {
+			continue
+		}
+		seen[dir.Path] = true
+
+		for _, metric := range storeDirMetrics {
+			if value, ok := dir.Values[metric.key]; ok {
+				ch <- prometheus.MustNewConstMetric(c.descs[metric.key], prometheus.GaugeValue, value, dir.Path, dir.Type)
+			}
+		}
+
+		readOnly := 0.0
+		flags := map[string]bool{}
+		for _, flag := range dir.Flags {
+			if flags[flag] {
+				continue
+			}
+			flags[flag] = true
+			if flag == "READ-ONLY" {
+				readOnly = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.flag, prometheus.GaugeValue, 1, dir.Path, dir.Type, flag)
+		}
+		ch <- prometheus.MustNewConstMetric(c.readOnly, prometheus.GaugeValue, readOnly, dir.Path, dir.Type)
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟的 mgr:storedir 页面，包含一个 aufs 和一个只读的 rock cache_dir
+var storeDirPageLines = strings.SplitAfter(`Store Directory Statistics:
+Store Entries          : 12345
+Maximum Swap Size      : 3072 KB
+Current Store Swap Size: 1536.00 KB
+Current Capacity       : 50.00% used, 50.00% free
+
+Store Directory #0 (aufs): /var/spool/squid
+FS Block Size 4096 Bytes
+First level subdirectories: 16
+Second level subdirectories: 256
+Maximum Size: 2048 KB
+Current Size: 1024.00 KB
+Percent Used: 50.00%
+Filemap bits in use: 300 of 16384 (2%)
+Filesystem Space in use: 5000/20000 KB (25%)
+Filesystem Inodes in use: 100/1000 (10%)
+Flags: SELECTED
+Removal policy: lru
+LRU reference age: 1.23 days
+
+Store Directory #1 (rock): /var/cache/squid/rock
+FS Block Size 1024 Bytes
+
+Maximum Size: 1024 KB
+Current Size: 512.00 KB 50.00%
+Maximum entries:        64
+Current entries:        16 25.00%
+Used slots:             16 25.00%
+Pending operations: 0 out of 0
+Flags: SELECTED READ-ONLY
+`, "\n")
+
+// 测试解析每个 cache_dir 的统计信息
+func TestParseStoreDirs(t *testing.T) {
+	dirs := parseStoreDirs(storeDirPageLines)
+	assert.Len(t, dirs, 2, "应解析出两个cache_dir")
+
+	assert.Equal(t, "aufs", dirs[0].Type)
+	assert.Equal(t, "/var/spool/squid", dirs[0].Path)
+	assert.Equal(t, 1024.0*1024, dirs[0].Values["size"], "大小应换算为字节")
+	assert.Equal(t, 2048.0*1024, dirs[0].Values["max_size"])
+	assert.Equal(t, 50.0, dirs[0].Values["capacity"])
+	assert.Equal(t, 300.0, dirs[0].Values["filemap_used"])
+	assert.Equal(t, 16384.0, dirs[0].Values["filemap_size"])
+	assert.Equal(t, 300.0, dirs[0].Values["entries"], "ufs类型使用filemap位数作为条目数")
+	assert.Equal(t, 20000.0*1024, dirs[0].Values["fs_size"])
+	assert.Equal(t, []string{"SELECTED"}, dirs[0].Flags)
+
+	assert.Equal(t, "rock", dirs[1].Type)
+	assert.Equal(t, 50.0, dirs[1].Values["capacity"], "rock类型的使用率在大小之后")
+	assert.Equal(t, 16.0, dirs[1].Values["entries"])
+	assert.Equal(t, 64.0, dirs[1].Values["max_entries"])
+	assert.Equal(t, []string{"SELECTED", "READ-ONLY"}, dirs[1].Flags)
+}
+
+// 测试 cache_dir 收集器的输出
+func TestSquidStoreDirCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{storeDirPage: storeDirPageLines}))
+
+	expected := `
+# HELP squid_storedir_capacity_percent Percentage of the cache_dir maximum size in use
+# TYPE squid_storedir_capacity_percent gauge
+squid_storedir_capacity_percent{path="/var/cache/squid/rock",type="rock"} 50
+squid_storedir_capacity_percent{path="/var/spool/squid",type="aufs"} 50
+# HELP squid_storedir_read_only Whether the cache_dir is read-only (1) or not (0)
+# TYPE squid_storedir_read_only gauge
+squid_storedir_read_only{path="/var/cache/squid/rock",type="rock"} 1
+squid_storedir_read_only{path="/var/spool/squid",type="aufs"} 0
+# HELP squid_storedir_size_bytes Current size of the cache_dir in bytes
+# TYPE squid_storedir_size_bytes gauge
+squid_storedir_size_bytes{path="/var/cache/squid/rock",type="rock"} 524288
+squid_storedir_size_bytes{path="/var/spool/squid",type="aufs"} 1.048576e+06
+`
+	collector := NewSquidStoreDirCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_storedir_capacity_percent", "squid_storedir_read_only", "squid_storedir_size_bytes"))
+	assert.Equal(t, 3, testutil.CollectAndCount(collector, "squid_storedir_flag"), "每个标志一个序列")
+}
@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 磁盘I/O统计信息所在的管理页面，squidaio_counts 只在使用 aufs 等线程化存储时存在
+const (
+	storeIOPage   = "store_io"
+	aioCountsPage = "squidaio_counts"
+)
+
+// storeIOMetrics 是 mgr:store_io 中导出的统计项
+var storeIOMetrics = []struct {
+	key  string
+	name string
+	help string
+}{
+	{"create.calls", "create_calls_total", "Total number of store object create calls"},
+	{"create.select_fail", "create_select_fail_total", "Total number of create calls that found no cache_dir to store the object"},
+	{"create.create_fail", "create_fail_total", "Total number of create calls that failed on the selected cache_dir"},
+	{"create.success", "create_success_total", "Total number of successful create calls"},
+}
+
+// parseStoreIO 解析形如 "create.calls 1234" 的行，SMP模式下页面按进程分块时累加各进程的值
+func parseStoreIO(lines []string) map[string]float64 {
+	values := map[string]float64{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			values[fields[0]] += value
+		}
+	}
+	return values
+}
+
+// aioOperation 是 mgr:squidaio_counts 中一种异步I/O操作的请求数和完成数，没有完成数时 Serviced 为负
+type aioOperation struct {
+	Operation string
+	Requests  float64
+	Serviced  float64
+}
+
+// aioStats 是 mgr:squidaio_counts 的解析结果
+type aioStats struct {
+	Operations []aioOperation
+	Queue      float64
+	// Threads 为每个I/O线程处理的请求数，键为线程编号
+	Threads map[string]float64
+}
+
+// parseAIOCounts 解析 mgr:squidaio_counts。"ASYNC IO Counters:" 表格的行形如 "open\t120\t118"，
+// 没有完成数的操作为 "-"，其中的 queue 行为当前队列长度；"Threads Status:" 表格的行形如 "1\t0x7f00\t5000"。
+// SMP模式下每个进程各有一份表格，相同的操作和编号相同的线程在收集时累加
+func parseAIOCounts(lines []string) aioStats {
+	stats := aioStats{Threads: map[string]float64{}}
+	section := ""
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") {
+			section = trimmed
+			continue
+		}
+		fields := strings.Fields(trimmed)
+		if len(fields) != 3 {
+			continue
+		}
+
+		switch section {
+		case "ASYNC IO Counters:":
+			requests, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				continue
+			}
+			if fields[0] == "queue" {
+				stats.Queue += requests
+				continue
+			}
+			serviced, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				serviced = -1
+			}
+			stats.Operations = append(stats.Operations, aioOperation{Operation: fields[0], Requests: requests, Serviced: serviced})
+		case "Threads Status:":
+			if _, err := strconv.Atoi(fields[0]); err != nil {
+				continue
+			}
+			if requests, err := strconv.ParseFloat(fields[2], 64); err == nil {
+				stats.Threads[fields[0]] += requests
+			}
+		}
+	}
+	return stats
+}
+
+// GetSquidStoreIO 返回 mgr:store_io 的收集器
+func GetSquidStoreIO(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidStoreIOCollector(source)}
+}
+
+// SquidStoreIOCollector 导出 mgr:store_io 中创建缓存对象的调用次数和失败次数
+type SquidStoreIOCollector struct {
+	source *SnapshotSource
+	descs  []*prometheus.Desc
+}
+
+// NewSquidStoreIOCollector 创建新的存储I/O收集器
+func NewSquidStoreIOCollector(source *SnapshotSource) *SquidStoreIOCollector {
+	collector := &SquidStoreIOCollector{source: source}
+	for _, metric := range storeIOMetrics {
+		collector.descs = append(collector.descs, prometheus.NewDesc(
+			prometheus.BuildFQName("squid", "store_io", metric.name), metric.help, nil, nil))
+	}
+	return collector
+}
+
+// Describe 实现了Collector接口
+func (c *SquidStoreIOCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+// Collect实现了Collector接口，用于采集指标，页面中没有的统计项不输出
+func (c *SquidStoreIOCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(storeIOPage)
+	if err != nil {
+		return
+	}
+	values := parseStoreIO(lines)
+	for i, metric := range storeIOMetrics {
+		if value, ok := values[metric.key]; ok {
+			ch <- prometheus.MustNewConstMetric(c.descs[i], prometheus.CounterValue, value)
+		}
+	}
+}
+
+// GetSquidAIOCounts 返回 mgr:squidaio_counts 的收集器
+func GetSquidAIOCounts(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidAIOCountsCollector(source)}
+}
+
+// SquidAIOCountsCollector 按操作导出异步I/O的请求数和完成数、队列长度以及每个I/O线程处理的请求数
+type SquidAIOCountsCollector struct {
+	source         *SnapshotSource
+	requests       *prometheus.Desc
+	serviced       *prometheus.Desc
+	queue          *prometheus.Desc
+	threadRequests *prometheus.Desc
+}
+
+// NewSquidAIOCountsCollector 创建新的异步I/O收集器
+func NewSquidAIOCountsCollector(source *SnapshotSource) *SquidAIOCountsCollector {
+	return &SquidAIOCountsCollector{
+		source: source,
+		requests: prometheus.NewDesc("squid_aio_requests_total",
+			"Total number of asynchronous disk I/O requests by operation", []string{"operation"}, nil),
+		serviced: prometheus.NewDesc("squid_aio_serviced_total",
+			"Total number of asynchronous disk I/O requests completed by operation", []string{"operation"}, nil),
+		queue: prometheus.NewDesc("squid_aio_queue_length",
+			"Number of asynchronous disk I/O requests waiting in the queue", nil, nil),
+		threadRequests: prometheus.NewDesc("squid_aio_thread_requests_total",
+			"Total number of asynchronous disk I/O requests handled by the I/O thread", []string{"thread"}, nil),
+	}
+}
+
+// Describe 实现了Collector接口
+func (c *SquidAIOCountsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.serviced
+	ch <- c.queue
+	ch <- c.threadRequests
+}
+
+// Collect实现了Collector接口，用于采集指标，未使用线程化存储时页面不存在，不输出任何指标
+func (c *SquidAIOCountsCollector) Collect(ch chan<- prometheus.Metric) {
+	lines, err := c.source.Current().Page(aioCountsPage)
+	if err != nil {
+		return
+	}
+	stats := parseAIOCounts(lines)
+	if len(stats.Operations) == 0 {
+		return
+	}
+
+	var operations []aioOperation
+	index := map[string]int{}
+	for _, operation := range stats.Operations {
+		i, ok := index[operation.Operation]
+		if !ok {
+			index[operation.Operation] = len(operations)
+			operations = append(operations, operation)
+			continue
+		}
+		operations[i].Requests += operation.Requests
+		if operations[i].Serviced >= 0 && operation.Serviced >= 0 {
+			operations[i].Serviced += operation.Serviced
+		}
+	}
+	for _, operation := range operations {
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, operation.Requests, operation.Operation)
+		if operation.Serviced >= 0 {
+			ch <- prometheus.MustNewConstMetric(c.serviced, prometheus.CounterValue, operation.Serviced, operation.Operation)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.queue, prometheus.GaugeValue, stats.Queue)
+	for thread, requests := range stats.Threads {
+		ch <- prometheus.MustNewConstMetric(c.threadRequests, prometheus.CounterValue, requests, thread)
+	}
+}
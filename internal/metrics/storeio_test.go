@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试解析存储I/O统计信息
+func TestParseStoreIO(t *testing.T) {
+	page := readPage(t, "store_io")
+	created := map[string]float64{
+		"create.calls":       1540,
+		"create.select_fail": 0,
+		"create.create_fail": 2,
+		"create.success":     1538,
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected map[string]float64
+	}{
+		{
+			name:     "创建调用的统计项",
+			lines:    page,
+			expected: created,
+		},
+		{
+			name: "SMP模式下累加各进程的值",
+			lines: []string{
+				"by kid1 {\n", page[0], page[1], page[3], "} by kid1\n", "\n",
+				"by kid2 {\n", page[0], "create.calls 60\n", "create.create_fail 1\n", "} by kid2\n", "\n",
+			},
+			expected: map[string]float64{"create.calls": 1600, "create.create_fail": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseStoreIO(tt.lines))
+		})
+	}
+}
+
+// 测试解析异步I/O统计信息，没有完成数的操作为-1
+func TestParseAIOCounts(t *testing.T) {
+	page := readPage(t, "squidaio_counts")
+	operations := []aioOperation{
+		{Operation: "open", Requests: 1210, Serviced: 1210},
+		{Operation: "close", Requests: 1208, Serviced: 1208},
+		{Operation: "cancel", Requests: 3, Serviced: -1},
+		{Operation: "write", Requests: 8840, Serviced: 8838},
+		{Operation: "read", Requests: 22104, Serviced: 22104},
+		{Operation: "stat", Requests: 0, Serviced: 0},
+		{Operation: "unlink", Requests: 320, Serviced: 320},
+		{Operation: "check_callback", Requests: 912344, Serviced: -1},
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected aioStats
+	}{
+		{
+			name:  "操作、队列和线程表",
+			lines: page,
+			expected: aioStats{
+				Operations: operations,
+				Queue:      2,
+				Threads:    map[string]float64{"1": 11020, "2": 10870, "3": 9680},
+			},
+		},
+		{
+			name:  "没有完成数的操作和queue行",
+			lines: []string{page[0], page[1], page[4], page[9], page[10]},
+			expected: aioStats{
+				Operations: []aioOperation{
+					{Operation: "cancel", Requests: 3, Serviced: -1},
+					{Operation: "check_callback", Requests: 912344, Serviced: -1},
+				},
+				Queue:   2,
+				Threads: map[string]float64{},
+			},
+		},
+		{
+			name:     "五列和四列的表头被跳过",
+			lines:    []string{page[0], page[1], page[13], page[14]},
+			expected: aioStats{Threads: map[string]float64{}},
+		},
+		{
+			name: "SMP模式下各进程的表格",
+			lines: []string{
+				"by kid1 {\n", page[0], page[1], page[2], page[10], page[13], page[14], page[15], "} by kid1\n", "\n",
+				"by kid2 {\n", page[0], page[1], "open\t40\t39\n", "queue\t1\t-\n", page[13], page[14], "1\t0x7f2b1c7fe640\t500\n", "} by kid2\n", "\n",
+			},
+			expected: aioStats{
+				Operations: []aioOperation{
+					{Operation: "open", Requests: 1210, Serviced: 1210},
+					{Operation: "open", Requests: 40, Serviced: 39},
+				},
+				Queue:   3,
+				Threads: map[string]float64{"1": 11520},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseAIOCounts(tt.lines))
+		})
+	}
+}
+
+// 测试存储I/O和异步I/O收集器的输出
+func TestSquidStoreIOCollectors(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{
+		storeIOPage:   readPage(t, "store_io"),
+		aioCountsPage: readPage(t, "squidaio_counts"),
+	}))
+
+	expected := `
+# HELP squid_store_io_create_fail_total Total number of create calls that failed on the selected cache_dir
+# TYPE squid_store_io_create_fail_total counter
+squid_store_io_create_fail_total 2
+`
+	storeIO := NewSquidStoreIOCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(storeIO, strings.NewReader(expected), "squid_store_io_create_fail_total"))
+	assert.Equal(t, 4, testutil.CollectAndCount(storeIO))
+
+	expected = `
+# HELP squid_aio_queue_length Number of asynchronous disk I/O requests waiting in the queue
+# TYPE squid_aio_queue_length gauge
+squid_aio_queue_length 2
+# HELP squid_aio_serviced_total Total number of asynchronous disk I/O requests completed by operation
+# TYPE squid_aio_serviced_total counter
+squid_aio_serviced_total{operation="close"} 1208
+squid_aio_serviced_total{operation="open"} 1210
+squid_aio_serviced_total{operation="read"} 22104
+squid_aio_serviced_total{operation="stat"} 0
+squid_aio_serviced_total{operation="unlink"} 320
+squid_aio_serviced_total{operation="write"} 8838
+`
+	aio := NewSquidAIOCountsCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(aio, strings.NewReader(expected), "squid_aio_queue_length", "squid_aio_serviced_total"))
+	assert.Equal(t, 8, testutil.CollectAndCount(aio, "squid_aio_requests_total"))
+
+	// 未使用线程化存储时页面不存在
+	missing := NewSquidAIOCountsCollector(NewSnapshotSource(newPageClient(map[string][]string{})))
+	assert.Equal(t, 0, testutil.CollectAndCount(missing))
+}
+
+// 测试SMP模式下异步I/O收集器累加各进程的相同操作
+func TestSquidAIOCountsCollectorSMP(t *testing.T) {
+	page := readPage(t, "squidaio_counts")
+	var lines []string
+	for _, kid := range []string{"1", "2"} {
+		lines = append(lines, "by kid"+kid+" {\n")
+		lines = append(lines, page[:11]...)
+		lines = append(lines, "} by kid"+kid+"\n", "\n")
+	}
+	source := NewSnapshotSource(newPageClient(map[string][]string{aioCountsPage: lines}))
+
+	expected := `
+# HELP squid_aio_queue_length Number of asynchronous disk I/O requests waiting in the queue
+# TYPE squid_aio_queue_length gauge
+squid_aio_queue_length 4
+# HELP squid_aio_requests_total Total number of asynchronous disk I/O requests by operation
+# TYPE squid_aio_requests_total counter
+squid_aio_requests_total{operation="cancel"} 6
+squid_aio_requests_total{operation="check_callback"} 1.824688e+06
+squid_aio_requests_total{operation="close"} 2416
+squid_aio_requests_total{operation="open"} 2420
+squid_aio_requests_total{operation="read"} 44208
+squid_aio_requests_total{operation="stat"} 0
+squid_aio_requests_total{operation="unlink"} 640
+squid_aio_requests_total{operation="write"} 17680
+`
+	aio := NewSquidAIOCountsCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(aio, strings.NewReader(expected), "squid_aio_queue_length", "squid_aio_requests_total"))
+	assert.Equal(t, 6, testutil.CollectAndCount(aio, "squid_aio_serviced_total"), "没有完成数的操作不输出完成数")
+}
@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TLSOptions 描述连接缓存管理器时使用的TLS参数
+type TLSOptions struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	Insecure   bool
+}
+
+// buildTLSConfig 根据TLS参数创建tls.Config
+func buildTLSConfig(opts *TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.Insecure,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", opts.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("both client certificate and key must be set")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// TLSStatus 记录最近一次TLS握手的结果，Attempted 为false时还没有建立过TCP连接并进行握手
+type TLSStatus struct {
+	Enabled     bool
+	Attempted   bool
+	HandshakeOK bool
+	NotAfter    time.Time
+}
+
+// tlsState 在连接处理程序中保存最近一次握手的结果
+type tlsState struct {
+	mu     sync.Mutex
+	status TLSStatus
+}
+
+// record 根据握手结果更新状态，记录对端证书链中最早的过期时间，
+// 握手失败时清除过期时间，避免继续输出上一次成功握手时的证书
+func (s *tlsState) record(conn *tls.Conn, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.Attempted = true
+	s.status.HandshakeOK = err == nil
+	if err != nil {
+		s.status.NotAfter = time.Time{}
+		return
+	}
+
+	var earliest time.Time
+	for _, cert := range conn.ConnectionState().PeerCertificates {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	s.status.NotAfter = earliest
+}
+
+// get 返回当前状态的副本
+func (s *tlsState) get() TLSStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// tlsMetrics 输出缓存管理器TLS连接相关的指标
+type tlsMetrics struct {
+	handshakeOK *prometheus.Desc
+	certExpiry  *prometheus.Desc
+}
+
+func newTLSMetrics() *tlsMetrics {
+	return &tlsMetrics{
+		handshakeOK: prometheus.NewDesc(
+			"squid_tls_handshake_success",
+			"Whether the last TLS handshake with the cache manager succeeded (1) or not (0)",
+			nil,
+			nil,
+		),
+		certExpiry: prometheus.NewDesc(
+			"squid_tls_cert_not_after_timestamp_seconds",
+			"Earliest expiry of the certificate chain presented by the cache manager, in unix seconds",
+			nil,
+			nil,
+		),
+	}
+}
+
+func (m *tlsMetrics) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.handshakeOK
+	ch <- m.certExpiry
+}
+
+func (m *tlsMetrics) collect(ch chan<- prometheus.Metric, status TLSStatus) {
+	if !status.Enabled || !status.Attempted {
+		return
+	}
+
+	handshakeOK := 0.0
+	if status.HandshakeOK {
+		handshakeOK = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(m.handshakeOK, prometheus.GaugeValue, handshakeOK)
+
+	if !status.NotAfter.IsZero() {
+		ch <- prometheus.MustNewConstMetric(m.certExpiry, prometheus.GaugeValue, float64(status.NotAfter.Unix()))
+	}
+}
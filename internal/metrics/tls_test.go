@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// 启动一个模拟 https_port 上缓存管理器的TLS服务器
+func newTLSMgrServer(t *testing.T) (*httptest.Server, string, int) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/squid-internal-mgr/counters" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "client_http.requests = 42\nclient_http.hits = 7\n")
+	}))
+	t.Cleanup(server.Close)
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	assert.NoError(t, err)
+	return server, host, port
+}
+
+// rejectingListener 在 reject 为true时接受TCP连接后立即关闭，使TLS握手失败
+type rejectingListener struct {
+	net.Listener
+	reject atomic.Bool
+}
+
+func (l *rejectingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || !l.reject.Load() {
+			return conn, err
+		}
+		conn.Close()
+	}
+}
+
+// 测试跳过证书校验时通过TLS获取计数器
+func TestTLSClientInsecure(t *testing.T) {
+	_, host, port := newTLSMgrServer(t)
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{Insecure: true},
+	})
+
+	counters, err := client.GetCounters()
+	assert.NoError(t, err, "TLS请求应成功")
+	assert.Len(t, counters, 2, "应解析出两个计数器")
+
+	status := client.TLSStatus()
+	assert.True(t, status.Enabled, "应启用TLS")
+	assert.True(t, status.HandshakeOK, "握手应成功")
+	assert.False(t, status.NotAfter.IsZero(), "应记录证书过期时间")
+}
+
+// 测试使用自定义CA和SNI校验服务器证书
+func TestTLSClientCustomCA(t *testing.T) {
+	server, host, port := newTLSMgrServer(t)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, certPEM, 0644))
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{CAFile: caFile, ServerName: "example.com"},
+	})
+
+	_, err := client.GetCounters()
+	assert.NoError(t, err, "使用自定义CA时请求应成功")
+	assert.True(t, client.TLSStatus().HandshakeOK, "握手应成功")
+}
+
+// 测试证书校验失败时记录握手失败
+func TestTLSClientVerifyFailure(t *testing.T) {
+	_, host, port := newTLSMgrServer(t)
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{},
+	})
+
+	_, err := client.GetCounters()
+	assert.Error(t, err, "未信任的证书应导致错误")
+	assert.False(t, client.TLSStatus().HandshakeOK, "握手应失败")
+}
+
+// 测试握手成功后再失败时不再输出上一次的证书过期时间
+func TestTLSClientFailureAfterSuccess(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "client_http.requests = 42\n")
+	}))
+	listener := &rejectingListener{Listener: server.Listener}
+	server.Listener = listener
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	host, portStr, err := net.SplitHostPort(listener.Addr().String())
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	assert.NoError(t, err)
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{Insecure: true},
+	})
+	metrics := newTLSMetrics()
+	count := func() int {
+		ch := make(chan prometheus.Metric, 10)
+		metrics.collect(ch, client.TLSStatus())
+		close(ch)
+		return len(ch)
+	}
+
+	_, err = client.GetCounters()
+	assert.NoError(t, err)
+	assert.False(t, client.TLSStatus().NotAfter.IsZero(), "应记录证书过期时间")
+	assert.Equal(t, 2, count(), "应输出握手结果和证书过期时间")
+
+	listener.reject.Store(true)
+	_, err = client.GetCounters()
+	assert.Error(t, err, "握手失败时请求应失败")
+	status := client.TLSStatus()
+	assert.False(t, status.HandshakeOK, "握手应失败")
+	assert.True(t, status.NotAfter.IsZero(), "握手失败时应清除证书过期时间")
+	assert.Equal(t, 1, count(), "握手失败时只输出握手结果")
+}
+
+// 测试TCP连接失败时没有进行握手，不输出TLS指标
+func TestTLSClientConnectFailure(t *testing.T) {
+	server, host, port := newTLSMgrServer(t)
+	server.Close()
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{Insecure: true},
+	})
+	_, err := client.GetCounters()
+	assert.Error(t, err, "服务器关闭后请求应失败")
+	assert.False(t, client.TLSStatus().Attempted, "连接失败时不应记录握手")
+
+	ch := make(chan prometheus.Metric, 10)
+	newTLSMetrics().collect(ch, client.TLSStatus())
+	close(ch)
+	assert.Empty(t, ch, "没有进行握手时不输出TLS指标")
+}
+
+// 测试通过TLS发送的请求行使用 https:// 形式
+func TestTLSClientRequestLine(t *testing.T) {
+	var requestURI string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		fmt.Fprint(w, "client_http.requests = 42\n")
+	}))
+	t.Cleanup(server.Close)
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	assert.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	assert.NoError(t, err)
+
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{Insecure: true},
+	})
+	_, err = client.GetCounters()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://"+net.JoinHostPort(host, portStr)+"/squid-internal-mgr/counters", requestURI)
+}
+
+// 测试TLS配置错误
+func TestBuildTLSConfigErrors(t *testing.T) {
+	_, err := buildTLSConfig(&TLSOptions{CAFile: "/nonexistent/ca.pem"})
+	assert.Error(t, err, "CA文件不存在应返回错误")
+
+	_, err = buildTLSConfig(&TLSOptions{CertFile: "/tmp/cert.pem"})
+	assert.Error(t, err, "只配置证书未配置私钥应返回错误")
+
+	config, err := buildTLSConfig(&TLSOptions{ServerName: "squid.example.com", Insecure: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "squid.example.com", config.ServerName, "SNI应匹配")
+	assert.True(t, config.InsecureSkipVerify, "应跳过证书校验")
+}
+
+// 测试收集器输出TLS指标
+func TestSquidCollectorTLSMetrics(t *testing.T) {
+	_, host, port := newTLSMgrServer(t)
+
+	collector := NewSquidCollector(&SquidConfig{
+		Hostname:  host,
+		Port:      port,
+		Transport: TransportHTTP,
+		TLS:       &TLSOptions{Insecure: true},
+	})
+
+	ch := make(chan prometheus.Metric, 10)
+	collector.BeginScrape(context.Background())
+	collector.Collect(ch)
+	collector.EndScrape()
+	close(ch)
+
+	var names []string
+	for metric := range ch {
+		names = append(names, metric.Desc().String())
+	}
+	assert.Len(t, names, 3, "应输出up、握手结果和证书过期时间")
+	assert.Contains(t, names[1], "squid_tls_handshake_success")
+	assert.Contains(t, names[2], "squid_tls_cert_not_after_timestamp_seconds")
+}
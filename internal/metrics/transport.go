@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 缓存管理器请求方式
+const (
+	// TransportAuto 优先使用 HTTP 方式，被拒绝时回退到 cache_object 方式，并记住可用的方式
+	TransportAuto = "auto"
+	// TransportHTTP 使用 Squid 4+ 推荐的 http://host:port/squid-internal-mgr/<action>
+	TransportHTTP = "http"
+	// TransportCacheObject 使用传统的 cache_object://localhost/<action>
+	TransportCacheObject = "cache_object"
+
+	defaultMgrPath = "/squid-internal-mgr/"
+)
+
+// ValidTransport 判断请求方式是否受支持
+func ValidTransport(transport string) bool {
+	switch transport {
+	case TransportAuto, TransportHTTP, TransportCacheObject:
+		return true
+	}
+	return false
+}
+
+// MgrEndpoint 表示从 scrape_uri 解析出的缓存管理器地址
+type MgrEndpoint struct {
+	Transport string
+	Hostname  string
+	Port      int
+	MgrPath   string
+	TLS       bool
+}
+
+// ParseScrapeURI 解析 scrape_uri，支持以下形式:
+//
+//	http://host:port/squid-internal-mgr/
+//	https://host:port/squid-internal-mgr/
+//	cache_object://host:port/
+//
+// 未指定端口时使用3128，http方式未指定路径时使用 /squid-internal-mgr/
+func ParseScrapeURI(uri string) (*MgrEndpoint, error) {
+	// url.Parse 不接受带下划线的协议名，先替换为等价的合法名称
+	const cacheObjectScheme = "cache-object"
+	rawURI := uri
+	if strings.HasPrefix(rawURI, TransportCacheObject+"://") {
+		rawURI = cacheObjectScheme + strings.TrimPrefix(rawURI, TransportCacheObject)
+	}
+
+	parsed, err := url.Parse(rawURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scrape uri %q: %w", uri, err)
+	}
+
+	endpoint := &MgrEndpoint{}
+	switch parsed.Scheme {
+	case "http", "https":
+		endpoint.Transport = TransportHTTP
+		endpoint.MgrPath = normalizeMgrPath(parsed.Path)
+		endpoint.TLS = parsed.Scheme == "https"
+	case cacheObjectScheme:
+		endpoint.Transport = TransportCacheObject
+	default:
+		return nil, fmt.Errorf("unsupported scrape uri scheme: %q", parsed.Scheme)
+	}
+
+	endpoint.Hostname = parsed.Hostname()
+	if endpoint.Hostname == "" {
+		return nil, fmt.Errorf("scrape uri %q has no host", uri)
+	}
+
+	endpoint.Port = 3128
+	if port := parsed.Port(); port != "" {
+		endpoint.Port, err = strconv.Atoi(port)
+		if err != nil || endpoint.Port <= 0 || endpoint.Port > 65535 {
+			return nil, fmt.Errorf("invalid port in scrape uri %q", uri)
+		}
+	}
+
+	return endpoint, nil
+}
+
+// normalizeMgrPath 保证管理路径以 / 开头和结尾
+func normalizeMgrPath(path string) string {
+	if path == "" || path == "/" {
+		return defaultMgrPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
+
+// mgrStatusError 表示Squid返回了非200状态码，通常意味着请求方式不被接受
+type mgrStatusError struct {
+	code int
+}
+
+func (e *mgrStatusError) Error() string {
+	return fmt.Sprintf("Non success code %d while fetching metrics", e.code)
+}
+
+// requestHead 构建指定请求方式下的请求行和Host头，启用TLS时HTTP方式的请求行使用 https://
+func (c *CacheObjectClient) requestHead(transport, endpoint string) (string, string) {
+	if transport == TransportHTTP {
+		host := net.JoinHostPort(c.hostname, strconv.Itoa(c.port))
+		protocol := httpRequestProtocol
+		if c.tls {
+			protocol = httpsRequestProtocol
+		}
+		return fmt.Sprintf(protocol, host, c.mgrPath, endpoint), "Host: " + host
+	}
+	return fmt.Sprintf(requestProtocol, endpoint), "Host: localhost"
+}
+
+// transportOrder 返回本次请求依次尝试的请求方式
+func (c *CacheObjectClient) transportOrder() []string {
+	switch c.transport {
+	case TransportHTTP, TransportCacheObject:
+		return []string{c.transport}
+	}
+
+	c.mu.Lock()
+	detected := c.detected
+	c.mu.Unlock()
+
+	if detected == TransportCacheObject {
+		return []string{TransportCacheObject, TransportHTTP}
+	}
+	return []string{TransportHTTP, TransportCacheObject}
+}
+
+// rememberTransport 记录自动探测到的可用请求方式
+func (c *CacheObjectClient) rememberTransport(transport string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.detected = transport
+}
+
+// DetectedTransport 返回自动探测到的请求方式，尚未成功请求时为空
+func (c *CacheObjectClient) DetectedTransport() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.detected
+}
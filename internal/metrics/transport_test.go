@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 根据请求行返回不同响应的模拟连接处理程序
+type scriptedConnectionHandler struct {
+	// acceptHTTP 为true时接受 /squid-internal-mgr/ 请求，否则只接受 cache_object 请求
+	acceptHTTP bool
+	conns      []*mockConn
+}
+
+func (h *scriptedConnectionHandler) connect(ctx context.Context) (net.Conn, error) {
+	conn := newMockConn()
+	conn.On("Close").Return(nil)
+	h.conns = append(h.conns, conn)
+	return &scriptedConn{mockConn: conn, handler: h}, nil
+}
+
+// scriptedConn 在读取前根据已写入的请求准备响应
+type scriptedConn struct {
+	*mockConn
+	handler  *scriptedConnectionHandler
+	prepared bool
+}
+
+func (c *scriptedConn) Read(b []byte) (int, error) {
+	if !c.prepared {
+		c.prepared = true
+		request := c.writer.String()
+		isHTTP := strings.Contains(request, "/squid-internal-mgr/")
+		if isHTTP == c.handler.acceptHTTP {
+			prepareMockResponse(c.mockConn, 200, "client_http.requests = 42\n")
+		} else {
+			prepareMockResponse(c.mockConn, 400, "Bad Request")
+		}
+	}
+	return c.mockConn.Read(b)
+}
+
+// 测试解析 scrape_uri
+func TestParseScrapeURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		expected    *MgrEndpoint
+		expectError bool
+	}{
+		{
+			name: "HTTP方式默认路径",
+			uri:  "http://squid.example.com:3129",
+			expected: &MgrEndpoint{
+				Transport: TransportHTTP,
+				Hostname:  "squid.example.com",
+				Port:      3129,
+				MgrPath:   "/squid-internal-mgr/",
+			},
+		},
+		{
+			name: "HTTP方式自定义路径",
+			uri:  "http://127.0.0.1:3128/custom-mgr",
+			expected: &MgrEndpoint{
+				Transport: TransportHTTP,
+				Hostname:  "127.0.0.1",
+				Port:      3128,
+				MgrPath:   "/custom-mgr/",
+			},
+		},
+		{
+			name: "cache_object方式默认端口",
+			uri:  "cache_object://localhost/",
+			expected: &MgrEndpoint{
+				Transport: TransportCacheObject,
+				Hostname:  "localhost",
+				Port:      3128,
+			},
+		},
+		{
+			name:        "不支持的协议",
+			uri:         "ftp://localhost:21/",
+			expectError: true,
+		},
+		{
+			name:        "非法端口",
+			uri:         "http://localhost:99999/",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := ParseScrapeURI(tt.uri)
+			if tt.expectError {
+				assert.Error(t, err, "应返回错误")
+				return
+			}
+			assert.NoError(t, err, "不应返回错误")
+			assert.Equal(t, tt.expected, endpoint, "解析结果应匹配")
+		})
+	}
+}
+
+// 测试不同请求方式的请求行
+func TestRequestHead(t *testing.T) {
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname: "squid.example.com",
+		Port:     3128,
+	})
+
+	line, host := client.requestHead(TransportHTTP, "counters")
+	assert.Equal(t, "GET http://squid.example.com:3128/squid-internal-mgr/counters HTTP/1.0", line)
+	assert.Equal(t, "Host: squid.example.com:3128", host)
+
+	line, host = client.requestHead(TransportCacheObject, "counters")
+	assert.Equal(t, "GET cache_object://localhost/counters HTTP/1.0", line)
+	assert.Equal(t, "Host: localhost", host)
+
+	tlsClient := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname: "squid.example.com",
+		Port:     3129,
+		TLS:      &TLSOptions{},
+	})
+	line, host = tlsClient.requestHead(TransportHTTP, "counters")
+	assert.Equal(t, "GET https://squid.example.com:3129/squid-internal-mgr/counters HTTP/1.0", line)
+	assert.Equal(t, "Host: squid.example.com:3129", host)
+}
+
+// 测试自动模式在HTTP方式被拒绝时回退到cache_object方式并记住结果
+func TestAutoTransportFallback(t *testing.T) {
+	handler := &scriptedConnectionHandler{acceptHTTP: false}
+	client := NewCacheObjectClient(&CacheObjectRequest{Hostname: "localhost", Port: 3128})
+	client.ch = handler
+
+	counters, err := client.GetCounters()
+	assert.NoError(t, err, "回退后应成功")
+	assert.Len(t, counters, 1, "应解析出一个计数器")
+	assert.Len(t, handler.conns, 2, "首次请求应尝试两种方式")
+	assert.Equal(t, TransportCacheObject, client.DetectedTransport(), "应记住可用的方式")
+
+	_, err = client.GetCounters()
+	assert.NoError(t, err, "再次请求应成功")
+	assert.Len(t, handler.conns, 3, "记住方式后只需一次连接")
+	assert.Contains(t, handler.conns[2].writer.String(), "cache_object://localhost/counters")
+}
+
+// 测试固定请求方式时不回退
+func TestFixedTransportNoFallback(t *testing.T) {
+	handler := &scriptedConnectionHandler{acceptHTTP: false}
+	client := NewCacheObjectClient(&CacheObjectRequest{
+		Hostname:  "localhost",
+		Port:      3128,
+		Transport: TransportHTTP,
+	})
+	client.ch = handler
+
+	_, err := client.GetCounters()
+	assert.Error(t, err, "被拒绝时应返回错误")
+	assert.Len(t, handler.conns, 1, "固定方式只应尝试一次")
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// utilizationPage 是按多个时间窗口平均的计数器所在的管理页面
+const utilizationPage = "utilization"
+
+// utilizationUnits 把窗口标题中的时间单位映射为 window 标签中的单位，
+// 与 mgr:5min、mgr:60min 的 5m、60m 使用相同的写法
+var utilizationUnits = map[string]string{
+	"minute": "m",
+	"hour":   "h",
+	"day":    "d",
+	"week":   "w",
+}
+
+// utilizationWindow 把形如 "Last 5 minutes:"、"Last 8 hours:" 的窗口标题转换为 5m、8h 等 window 标签，
+// "Last hour:" 转换为与 mgr:60min 相同的 60m，没有数量的其他窗口数量为1，例如 "Last day:" 为 1d。
+// 不是窗口标题时返回空字符串，"Totals since cache startup:" 与 mgr:counters 重复，也返回空字符串
+func utilizationWindow(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "Last ") || !strings.HasSuffix(line, ":") {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(line, "Last "), ":"))
+	count, unit := "1", ""
+	switch len(fields) {
+	case 1:
+		unit = fields[0]
+	case 2:
+		count, unit = fields[0], fields[1]
+	default:
+		return ""
+	}
+	short, ok := utilizationUnits[strings.TrimSuffix(unit, "s")]
+	if !ok {
+		return ""
+	}
+	if count == "1" && short == "h" {
+		return serviceTimeWindow60m
+	}
+	return count + short
+}
+
+// utilizationValue 是 mgr:utilization 中某个窗口的一项平均值
+type utilizationValue struct {
+	averageValue
+	Window string
+}
+
+// parseUtilization 按窗口解析 mgr:utilization，每个窗口中的行与 mgr:5min 的格式相同，
+// 窗口之外的行（包括启动以来的总计）被忽略
+func parseUtilization(lines []string) []utilizationValue {
+	var values []utilizationValue
+	window := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") {
+			window = utilizationWindow(trimmed)
+			continue
+		}
+		if window == "" {
+			continue
+		}
+		if value, err := decodeAverageStrings(line); err == nil {
+			values = append(values, utilizationValue{averageValue: value, Window: window})
+		}
+	}
+	return values
+}
+
+// utilizationKey 返回窗口中一项平均值的查找键
+func utilizationKey(window, key string) string {
+	return window + " " + key
+}
+
+// utilizationValues 把页面解析为按窗口和键索引的数值
+func utilizationValues(lines []string) map[string]float64 {
+	values := map[string]float64{}
+	for _, value := range parseUtilization(lines) {
+		values[utilizationKey(value.Window, value.Key)] = value.Value
+	}
+	return values
+}
+
+// GetSquidUtilization 返回 mgr:utilization 的收集器
+func GetSquidUtilization(source *SnapshotSource) []prometheus.Collector {
+	return []prometheus.Collector{NewSquidUtilizationCollector(source)}
+}
+
+// SquidUtilizationCollector 把 mgr:utilization 中各窗口的平均值导出为带 window 标签的gauge，
+// 指标名为 squid_utilization_ 加上与计数器相同的名称和单位后缀
+type SquidUtilizationCollector struct {
+	source  *SnapshotSource
+	mu      sync.Mutex
+	metrics map[string]*perProcessMetric
+}
+
+// NewSquidUtilizationCollector 创建新的利用率收集器
+func NewSquidUtilizationCollector(source *SnapshotSource) *SquidUtilizationCollector {
+	return &SquidUtilizationCollector{
+		source:  source,
+		metrics: map[string]*perProcessMetric{},
+	}
+}
+
+// metric 返回指标名对应的指标，首次出现时创建，各窗口共用一个指标
+func (c *SquidUtilizationCollector) metric(name, help string) *perProcessMetric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if m, ok := c.metrics[name]; ok {
+		return m
+	}
+	m := newPerProcessMetric(name, help, prometheus.GaugeValue, "window")
+	c.metrics[name] = m
+	return m
+}
+
+// Describe 实现了Collector接口，指标随Squid的输出动态变化，不预先描述
+func (c *SquidUtilizationCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+// Collect实现了Collector接口，用于采集指标，SMP模式下同时输出各worker进程的值
+func (c *SquidUtilizationCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.source.Current()
+	lines, err := snapshot.Page(utilizationPage)
+	if err != nil {
+		return
+	}
+
+	values := newPageValues(utilizationPage, utilizationValues)
+	seen := map[string]bool{}
+	for _, value := range parseUtilization(lines) {
+		name, help := averageMetric("squid_utilization", value.averageValue)
+		if seen[name+" "+value.Window] {
+			continue
+		}
+		seen[name+" "+value.Window] = true
+		c.metric(name, help).collect(ch, snapshot, values.lookup(utilizationKey(value.Window, value.Key)), value.Window)
+	}
+}
@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试窗口标题的转换
+func TestUtilizationWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{name: "分钟", line: "Last 5 minutes:", expected: "5m"},
+		{name: "多个分钟", line: "Last 15 minutes:", expected: "15m"},
+		{name: "小时与mgr:60min相同", line: "Last hour:", expected: "60m"},
+		{name: "多个小时", line: "Last 8 hours:", expected: "8h"},
+		{name: "天", line: "Last day:", expected: "1d"},
+		{name: "多天", line: "Last 3 days:", expected: "3d"},
+		{name: "周", line: "Last week:", expected: "1w"},
+		{name: "启动以来的总计", line: "Totals since cache startup:", expected: ""},
+		{name: "页面标题", line: "Cache Utilisation:", expected: ""},
+		{name: "未知单位", line: "Last 2 fortnights:", expected: ""},
+		{name: "月没有对应的单位", line: "Last month:", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, utilizationWindow(tt.line))
+		})
+	}
+}
+
+// 测试按窗口解析 mgr:utilization，没有数据的窗口和启动以来的总计被忽略
+func TestParseUtilization(t *testing.T) {
+	page := readPage(t, "utilization")
+	value := func(window, key string, v float64, unit string) utilizationValue {
+		return utilizationValue{averageValue: averageValue{Key: key, Value: v, Unit: unit}, Window: window}
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []utilizationValue
+	}{
+		{
+			name:  "各窗口的平均值",
+			lines: page,
+			expected: []utilizationValue{
+				value("5m", "sample_start_time", 1700000000.123456, averageUnitTimestamp),
+				value("5m", "sample_end_time", 1700000300.123456, averageUnitTimestamp),
+				value("5m", "client_http.requests", 12.5, averageUnitRate),
+				value("5m", "client_http.hits", 3.25, averageUnitRate),
+				value("5m", "client_http.kbytes_in", 4.1, averageUnitRate),
+				value("5m", "client_http.all_median_svc_time", 0.012, averageUnitSeconds),
+				value("5m", "average_select_fd_period", 0, "/fd"),
+				value("5m", "median_select_fds", 0, ""),
+				value("5m", "syscalls.disk.reads", 1, averageUnitRate),
+				value("5m", "cpu_time", 10.5, averageUnitSeconds),
+				value("5m", "wall_time", 300, averageUnitSeconds),
+				value("5m", "cpu_usage", 3.5, averageUnitPercent),
+				value("60m", "client_http.requests", 10, averageUnitRate),
+				value("60m", "cpu_usage", 2.75, averageUnitPercent),
+			},
+		},
+		{
+			name:     "还没有数据的窗口",
+			lines:    page[23:31],
+			expected: nil,
+		},
+		{
+			name:     "启动以来的总计被忽略",
+			lines:    page[32:],
+			expected: nil,
+		},
+		{
+			name:  "总计之后的窗口",
+			lines: []string{page[32], page[34], page[19], page[20]},
+			expected: []utilizationValue{
+				value("60m", "client_http.requests", 10, averageUnitRate),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseUtilization(tt.lines))
+		})
+	}
+}
+
+// 测试利用率收集器使用与计数器相同的命名规则，并按窗口输出
+func TestSquidUtilizationCollector(t *testing.T) {
+	source := NewSnapshotSource(newPageClient(map[string][]string{utilizationPage: readPage(t, "utilization")}))
+
+	expected := `
+# HELP squid_utilization_client_http_requests_per_second Average rate of client_http.requests over the window, per second
+# TYPE squid_utilization_client_http_requests_per_second gauge
+squid_utilization_client_http_requests_per_second{window="5m"} 12.5
+squid_utilization_client_http_requests_per_second{window="60m"} 10
+# HELP squid_utilization_cpu_usage_percent Average of cpu_usage over the window, in percent
+# TYPE squid_utilization_cpu_usage_percent gauge
+squid_utilization_cpu_usage_percent{window="5m"} 3.5
+squid_utilization_cpu_usage_percent{window="60m"} 2.75
+`
+	collector := NewSquidUtilizationCollector(source)
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"squid_utilization_client_http_requests_per_second", "squid_utilization_cpu_usage_percent"))
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "squid_utilization_client_http_hits_per_second"))
+}
+
+// 测试SMP模式下利用率收集器通过 kidN/ 前缀输出各进程的值
+func TestSquidUtilizationCollectorPerProcess(t *testing.T) {
+	host, port := newMgrPagesServer(t, map[string]string{
+		"/squid-internal-mgr/kid1/counters":    "client_http.requests = 20\n",
+		"/squid-internal-mgr/kid2/counters":    "client_http.requests = 10\n",
+		"/squid-internal-mgr/utilization":      "Last 5 minutes:\nclient_http.requests = 12.500000/sec\n",
+		"/squid-internal-mgr/kid1/utilization": "Last 5 minutes:\nclient_http.requests = 10.000000/sec\n",
+		"/squid-internal-mgr/kid2/utilization": "Last 5 minutes:\nclient_http.requests = 2.500000/sec\n",
+	})
+	client := NewCacheObjectClient(&CacheObjectRequest{Hostname: host, Port: port, Transport: TransportHTTP})
+	source := NewSnapshotSource(client)
+	source.BeginScrape(context.Background())
+	defer source.EndScrape()
+
+	expected := `
+# HELP squid_utilization_client_http_requests_per_second Average rate of client_http.requests over the window, per second
+# TYPE squid_utilization_client_http_requests_per_second gauge
+squid_utilization_client_http_requests_per_second{process="all",window="5m"} 12.5
+squid_utilization_client_http_requests_per_second{process="kid1",window="5m"} 10
+squid_utilization_client_http_requests_per_second{process="kid2",window="5m"} 2.5
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewSquidUtilizationCollector(source), strings.NewReader(expected)))
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package server
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"uos-squid-exporter/config"
+	"uos-squid-exporter/internal/exporter"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+// 测试静态实例按 instance_name 标签输出各自的指标
+func TestStaticInstances(t *testing.T) {
+	first := strings.TrimPrefix(newMgrServer(t).URL, "http://")
+	second := strings.TrimPrefix(newMgrServer(t).URL, "http://")
+
+	content := fmt.Sprintf(`
+instances:
+  - name: first
+    scrape_uri: "http://%s/squid-internal-mgr/"
+    config_path: "/nonexistent/squid-first.conf"
+  - scrape_uri: "http://%s/squid-internal-mgr/"
+    extractTimes: false
+`, first, second)
+
+	var settings config.SquidSettings
+	assert.NoError(t, yaml.Unmarshal([]byte(content), &settings))
+	assert.Len(t, settings.Instances, 2)
+	assert.Equal(t, "localhost", settings.Instances[0].SquidHostname, "未设置的字段应使用默认值")
+	assert.Equal(t, 30*time.Second, settings.Instances[0].Timeout.Total, "未设置的超时应使用默认值")
+	assert.False(t, settings.Instances[1].ExtractTimes)
+
+	assert.NoError(t, exporter.InitSquidInstances(settings.Instances, exporter.DefaultConfig))
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	metricsHandler(w, req)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `squid_up{instance_name="first"} 1`, "第一个实例应使用配置的名称")
+	assert.Contains(t, body, fmt.Sprintf(`squid_up{instance_name="%s"} 1`, second), "未命名的实例应使用地址作为名称")
+	assert.Contains(t, body, `squid_client_http_requests_total{instance_name="first"} 42`)
+}
+
+// 测试实例名称重复时返回错误
+func TestStaticInstancesDuplicateName(t *testing.T) {
+	instances := []config.InstanceSettings{
+		{Name: "dup", Settings: config.DefaultSettings},
+		{Name: "dup", Settings: config.DefaultSettings},
+	}
+	assert.Error(t, exporter.InitSquidInstances(instances, exporter.DefaultConfig))
+}
+
+// 测试 collect 中有拼写错误的页面时返回错误，而不是只输出 up 指标
+func TestUnknownCollectPage(t *testing.T) {
+	settings := config.DefaultSettings
+	settings.Collect = []string{"countres"}
+	_, err := exporter.NewSquidConfig(settings, exporter.DefaultConfig)
+	assert.ErrorContains(t, err, `"countres"`)
+
+	instances := []config.InstanceSettings{{Name: "typo", Settings: settings}}
+	assert.ErrorContains(t, exporter.InitSquidInstances(instances, exporter.DefaultConfig), "instance typo")
+
+	settings.Collect = []string{"default", "events"}
+	_, err = exporter.NewSquidConfig(settings, exporter.DefaultConfig)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"uos-squid-exporter/config"
+	"uos-squid-exporter/internal/exporter"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	probePath = "/probe"
+	// defaultModuleName 未指定 module 参数时使用的模块，未配置时使用 squid: 段的设置
+	defaultModuleName = "default"
+)
+
+// lookupModule 按名称查找模块
+func (s *Server) lookupModule(name string) (config.ModuleSettings, error) {
+	if name == "" {
+		if module, ok := s.Modules[defaultModuleName]; ok {
+			return module, nil
+		}
+		return config.ModuleSettings{
+			Login:     s.ExporterConfig.Login,
+			Password:  s.ExporterConfig.Password,
+			Transport: s.ExporterConfig.Transport,
+			Insecure:  s.ExporterConfig.Insecure,
+			TLS:       s.ExporterConfig.TLS,
+			Timeout:   s.ExporterConfig.Timeout,
+		}, nil
+	}
+
+	module, ok := s.Modules[name]
+	if !ok {
+		return config.ModuleSettings{}, fmt.Errorf("unknown module %q", name)
+	}
+	return module, nil
+}
+
+// probeHandler 处理 /probe?target=host:port&module=name，每次请求为目标创建新的收集器
+func (s *Server) probeHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	module, err := s.lookupModule(params.Get("module"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	squidConfig, err := exporter.NewProbeConfig(target, module, s.CommonConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logrus.Debugf("Probing squid target %s:%d with module %q", squidConfig.Hostname, squidConfig.Port, params.Get("module"))
+
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	reg := exporter.NewProbeRegistry(ctx, squidConfig)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
@@ -0,0 +1,139 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"uos-squid-exporter/config"
+	"uos-squid-exporter/internal/exporter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 启动一个模拟 /squid-internal-mgr/ 的Squid
+func newMgrServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/squid-internal-mgr/counters":
+			fmt.Fprint(w, "client_http.requests = 42\n")
+		case "/squid-internal-mgr/info":
+			fmt.Fprint(w, "\tNumber of clients accessing cache:\t3\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func probe(s *Server, query url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", probePath+"?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+	s.probeHandler(w, req)
+	return w
+}
+
+// 测试 /probe 的参数校验
+func TestProbeHandlerBadRequest(t *testing.T) {
+	s := &Server{ExporterConfig: config.DefaultSettings}
+
+	w := probe(s, url.Values{})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "缺少target应返回400")
+
+	w = probe(s, url.Values{"target": {"localhost:3128"}, "module": {"missing"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "未知模块应返回400")
+
+	w = probe(s, url.Values{"target": {"localhost:99999"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "非法端口应返回400")
+
+	s.Modules = map[string]config.ModuleSettings{"bad": {Collect: []string{"nope"}}}
+	w = probe(s, url.Values{"target": {"localhost:3128"}, "module": {"bad"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "未知页面应返回400")
+}
+
+// 测试使用模块探测目标
+func TestProbeHandlerModule(t *testing.T) {
+	mgr := newMgrServer(t)
+	target := strings.TrimPrefix(mgr.URL, "http://")
+
+	s := &Server{
+		ExporterConfig: config.DefaultSettings,
+		Modules: map[string]config.ModuleSettings{
+			"counters_only": {Transport: "http", Collect: []string{"counters"}},
+		},
+	}
+
+	w := probe(s, url.Values{"target": {target}, "module": {"counters_only"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "squid_up 1", "目标应可达")
+	assert.Contains(t, body, "squid_client_http_requests_total 42", "应输出计数器")
+	assert.NotContains(t, body, "squid_info_Number_of_clients_accessing_cache", "不应请求info页面")
+}
+
+// 测试使用URL形式的目标和默认模块
+func TestProbeHandlerURLTarget(t *testing.T) {
+	mgr := newMgrServer(t)
+
+	s := &Server{ExporterConfig: config.DefaultSettings}
+	w := probe(s, url.Values{"target": {mgr.URL + "/squid-internal-mgr/"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "squid_up 1", "目标应可达")
+	assert.Contains(t, body, "squid_info_Number_of_clients_accessing_cache 3", "默认应请求全部页面")
+}
+
+// 测试首页的Probe链接
+func TestProbeLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		port     int
+		expected string
+	}{
+		{name: "主机名", hostname: "localhost", port: 3128, expected: "/probe?target=localhost%3A3128"},
+		{name: "IPv4地址", hostname: "10.0.0.1", port: 8080, expected: "/probe?target=10.0.0.1%3A8080"},
+		{name: "IPv6地址", hostname: "::1", port: 3128, expected: "/probe?target=%5B%3A%3A1%5D%3A3128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{ExporterConfig: config.Settings{SquidHostname: tt.hostname, SquidPort: tt.port}}
+			link := s.probeLink()
+			assert.Equal(t, tt.expected, link)
+
+			// 链接中的target应能被 /probe 解析
+			parsed, err := url.Parse(link)
+			assert.NoError(t, err)
+			host, port, err := net.SplitHostPort(parsed.Query().Get("target"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.hostname, host)
+			assert.Equal(t, strconv.Itoa(tt.port), port)
+		})
+	}
+}
+
+// 测试启动时检查模块的 collect
+func TestValidateModules(t *testing.T) {
+	err := exporter.ValidateModules(map[string]config.ModuleSettings{
+		"counters_only": {Collect: []string{"counters"}},
+		"all":           {},
+		"optional":      {Collect: []string{"default", "events"}},
+	})
+	assert.NoError(t, err, "已知页面应通过检查")
+
+	err = exporter.ValidateModules(map[string]config.ModuleSettings{
+		"counters_only": {Collect: []string{"counters"}},
+		"bad":           {Collect: []string{"counters", "nope"}},
+	})
+	assert.Error(t, err, "未知页面应返回错误")
+	assert.Contains(t, err.Error(), `module "bad"`, "错误中应包含模块名")
+	assert.Contains(t, err.Error(), `"nope"`, "错误中应包含未知页面")
+}
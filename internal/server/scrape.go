@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uos-squid-exporter/internal/exporter"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// scrapeTimeoutHeader 是Prometheus告知本次抓取超时时间（秒）的请求头
+	scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+	// scrapeTimeoutOffset 预留给编码和传输响应的时间
+	scrapeTimeoutOffset = 500 * time.Millisecond
+)
+
+// scrapeContext 根据请求头中的抓取超时创建本次抓取的上下文，
+// 请求头缺失或无效时只跟随请求本身的上下文
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+	value := r.Header.Get(scrapeTimeoutHeader)
+	if value == "" {
+		return context.WithCancel(ctx)
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		logrus.Warnf("Invalid %s header %q, ignoring", scrapeTimeoutHeader, value)
+		return context.WithCancel(ctx)
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// metricsHandler 在抓取上下文中收集并输出所有指标
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	reg := exporter.NewScrapeRegistry(ctx)
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
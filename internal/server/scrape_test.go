@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2025 UnionTech Software Technology Co., Ltd.
+// SPDX-License-Identifier: MIT
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试根据Prometheus请求头设置抓取超时
+func TestScrapeContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		hasDeadline bool
+		timeout     time.Duration
+	}{
+		{name: "无请求头", header: "", hasDeadline: false},
+		{name: "整数秒", header: "10", hasDeadline: true, timeout: 9500 * time.Millisecond},
+		{name: "小数秒", header: "2.5", hasDeadline: true, timeout: 2 * time.Second},
+		{name: "小于预留时间", header: "0.2", hasDeadline: true, timeout: 200 * time.Millisecond},
+		{name: "无效值", header: "abc", hasDeadline: false},
+		{name: "负数", header: "-1", hasDeadline: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set(scrapeTimeoutHeader, tt.header)
+			}
+
+			start := time.Now()
+			ctx, cancel := scrapeContext(req)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			assert.Equal(t, tt.hasDeadline, ok, "截止时间设置应匹配")
+			if ok {
+				assert.WithinDuration(t, start.Add(tt.timeout), deadline, 100*time.Millisecond, "超时时间应匹配")
+			}
+		})
+	}
+}
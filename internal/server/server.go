@@ -7,8 +7,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 	"uos-squid-exporter/config"
@@ -20,8 +23,6 @@ import (
 
 	"github.com/alecthomas/kingpin"
 	"github.com/dustin/go-humanize"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
@@ -32,12 +33,13 @@ type Server struct {
 	Name           string
 	Version        string
 	CommonConfig   exporter.Config
-	promReg        *prometheus.Registry
 	handlers       []HandlerFunc
 	ExitSignal     chan struct{}
 	Error          error
 	callback       sync.Once
 	ExporterConfig config.Settings
+	Modules        map[string]config.ModuleSettings
+	Instances      []config.InstanceSettings
 	server         *http.Server
 }
 
@@ -49,7 +51,6 @@ func NewServer(name, version string) *Server {
 		Name:         name,
 		Version:      version,
 		CommonConfig: exporter.DefaultConfig,
-		promReg:      prometheus.NewRegistry(),
 		ExitSignal:   make(chan struct{}),
 	}
 	return s
@@ -77,34 +78,67 @@ func (s *Server) SetUp() error {
 		return err
 	}
 
-	// 初始化Squid收集器
-	squidConfigPath := s.CommonConfig.SquidConfigPath
-	if squidConfigPath == "" {
-		squidConfigPath = "/etc/squid/squid.conf" // 默认路径
+	err = s.loadSquidSettings()
+	if err != nil {
+		logrus.Errorf("SetUp error: %v", err)
+		return err
+	}
+	// 模块中有未知页面时启动失败，而不是等到 /probe 请求时才返回400
+	err = exporter.ValidateModules(s.Modules)
+	if err != nil {
+		logrus.Errorf("SetUp error: %v", err)
+		return err
+	}
+
+	// 配置了 instances 时为每个实例初始化收集器，否则使用解析后的单个目标配置
+	if len(s.Instances) > 0 {
+		err = exporter.InitSquidInstances(s.Instances, s.CommonConfig)
+		if err != nil {
+			logrus.Errorf("SetUp error: %v", err)
+			return err
+		}
+	} else {
+		squidConfig, err := exporter.NewSquidConfig(s.ExporterConfig, s.CommonConfig)
+		if err != nil {
+			logrus.Errorf("SetUp error: %v", err)
+			return err
+		}
+		exporter.InitSquidCollector(squidConfig)
 	}
-	exporter.InitSquidCollector(squidConfigPath)
 
 	err = s.setupHttpServer()
 	if err != nil {
 		logrus.Errorf("SetUp error: %v", err)
 		return err
 	}
-	err = exporter.Unpack(&s.ExporterConfig)
+
+	return nil
+}
+
+// loadSquidSettings 按 默认值 < 配置文件 < 命令行参数 的优先级解析Squid目标配置
+func (s *Server) loadSquidSettings() error {
+	settings := config.SquidSettings{Settings: config.DefaultSettings}
+	err := exporter.Unpack(&settings)
 	if err != nil {
 		logrus.Error("Failed to unpack config: ", err)
 		logrus.Info("Use default config")
+		settings.Settings = config.DefaultSettings
 	}
-	if config.ScrapeUrl != nil {
-		logrus.Info("Using command-line parameters to override configuration parameters")
-		s.ExporterConfig.ScrapeUri = *config.ScrapeUrl
-	}
+	s.ExporterConfig = settings.Settings
+	s.ExporterConfig.ApplyFlags()
+	s.Modules = settings.Modules
+	s.Instances = settings.Instances
+	logrus.Infof("Loaded %d probe modules", len(s.Modules))
 
 	// 处理squid配置文件路径命令行参数
 	if exporter.SquidConfigPath != nil && *exporter.SquidConfigPath != "" {
 		logrus.Infof("Using command-line squid config path: %s", *exporter.SquidConfigPath)
 		s.CommonConfig.SquidConfigPath = *exporter.SquidConfigPath
 	}
-
+	if exporter.SquidConfigDir != nil && *exporter.SquidConfigDir != "" {
+		logrus.Infof("Using command-line squid config directory: %s", *exporter.SquidConfigDir)
+		s.CommonConfig.SquidConfigDir = *exporter.SquidConfigDir
+	}
 	return nil
 }
 
@@ -120,10 +154,7 @@ func (s *Server) setupLog() error {
 }
 
 func (s *Server) setupCmdArg() {
-	if config.ScrapeUrl != nil {
-		logrus.Info("Using command-line parameters to override configuration parameters")
-		s.ExporterConfig.ScrapeUri = *config.ScrapeUrl
-	}
+	s.ExporterConfig.ApplyFlags()
 }
 
 func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) {
@@ -173,11 +204,11 @@ func (s *Server) getName() string {
 }
 
 func (s *Server) setupHttpServer() error {
-	// 确保 exporter.RegisterPrometheus 被调用
-	exporter.RegisterPrometheus(s.promReg)
-
 	mux := http.NewServeMux()
-	mux.Handle(s.CommonConfig.MetricsPath, promhttp.HandlerFor(s.promReg, promhttp.HandlerOpts{}))
+	// 每次抓取使用独立的上下文，遵循Prometheus的抓取超时
+	mux.HandleFunc(s.CommonConfig.MetricsPath, metricsHandler)
+	// 多目标抓取接口，目标和模块由请求参数指定
+	mux.HandleFunc(probePath, s.probeHandler)
 
 	// 注册健康检查接口
 	mux.HandleFunc("/healthz", s.healthzHandler)
@@ -206,6 +237,10 @@ func (s *Server) setupHttpServer() error {
 				Text:    "Metrics",
 				Address: s.CommonConfig.MetricsPath,
 			},
+			{
+				Text:    "Probe",
+				Address: s.probeLink(),
+			},
 			{
 				Text:    "Health Check",
 				Address: "/healthz",
@@ -231,6 +266,12 @@ func (s *Server) setupHttpServer() error {
 	return nil
 }
 
+// probeLink 返回首页上探测本机配置的Squid的链接，IPv6地址需要加方括号
+func (s *Server) probeLink() string {
+	target := net.JoinHostPort(s.ExporterConfig.SquidHostname, strconv.Itoa(s.ExporterConfig.SquidPort))
+	return probePath + "?target=" + url.QueryEscape(target)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req := s.createRequest(w, r)
 	for _, handler := range s.handlers {
@@ -239,7 +280,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	promhttp.HandlerFor(s.promReg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	metricsHandler(w, r)
 }
 
 func (s *Server) Use(handlerFuncs ...HandlerFunc) {